@@ -5,6 +5,8 @@ import (
 	"os"
 	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -18,9 +20,24 @@ type RuntimeConfig struct {
 	// General configuration
 	jobsDirectory string
 	nodesDirectory string
+	maintenanceDirectory string
 	allowNoOwner bool
 	storageBackend string
 	healthCheckInterval time.Duration
+	reloadInterval time.Duration
+	roleLimits map[string]int
+	maxOutputBytes int
+	outputDirectory string
+	knownHostsFile string
+	hostKeyStore string
+	sshConfigFile string
+
+	// High availability
+	leaderElection string
+	leaseFile string
+	leaseTTL time.Duration
+	instanceName string
+	advertiseAddress string
 
 	// Elasticsearch storage backend
 	esHost string
@@ -42,6 +59,10 @@ func (c *RuntimeConfig) NodesDirectory() string {
 	return c.nodesDirectory
 }
 
+func (c *RuntimeConfig) MaintenanceDirectory() string {
+	return c.maintenanceDirectory
+}
+
 func (c *RuntimeConfig) AllowNoOwner() bool {
 	return c.allowNoOwner
 }
@@ -82,15 +103,88 @@ func (c *RuntimeConfig) HealthCheckInterval() time.Duration {
 	return c.healthCheckInterval
 }
 
+func (c *RuntimeConfig) ReloadInterval() time.Duration {
+	return c.reloadInterval
+}
+
+func (c *RuntimeConfig) RoleLimits() map[string]int {
+	return c.roleLimits
+}
+
+func (c *RuntimeConfig) MaxOutputBytes() int {
+	return c.maxOutputBytes
+}
+
+func (c *RuntimeConfig) OutputDirectory() string {
+	return c.outputDirectory
+}
+
+func (c *RuntimeConfig) KnownHostsFile() string {
+	return c.knownHostsFile
+}
+
+func (c *RuntimeConfig) HostKeyStore() string {
+	return c.hostKeyStore
+}
+
+func (c *RuntimeConfig) SshConfigFile() string {
+	return c.sshConfigFile
+}
+
+func (c *RuntimeConfig) LeaderElectionEnabled() bool {
+	return c.leaderElection != ""
+}
+
+func (c *RuntimeConfig) LeaderElection() string {
+	return c.leaderElection
+}
+
+func (c *RuntimeConfig) LeaseFile() string {
+	return c.leaseFile
+}
+
+func (c *RuntimeConfig) LeaseTTL() time.Duration {
+	return c.leaseTTL
+}
+
+func (c *RuntimeConfig) InstanceName() string {
+	return c.instanceName
+}
+
+func (c *RuntimeConfig) AdvertiseAddress() string {
+	return c.advertiseAddress
+}
+
 func (c *RuntimeConfig) PopulateFromFlags() error {
 	var healthCheckInterval string
+	var reloadInterval string
+	var leaseTTL string
+	var roleLimits string
 	var err error
 
+	hostname, _ := os.Hostname()
+
 	flag.StringVar(&c.jobsDirectory, "jobsDirectory", "/etc/distcron/jobs.d", "Directory from which to load job definitions")
 	flag.StringVar(&c.nodesDirectory, "nodesDirectory", "/etc/distcron/nodes.d", "Directory from which to load node definitions")
+	flag.StringVar(&c.maintenanceDirectory, "maintenanceDirectory", "", "Directory from which to load maintenance windows (and to store windows created through the REST API)")
 	flag.BoolVar(&c.allowNoOwner, "allowNoOwner", false, "Set to allow jobs to have no owners")
 	flag.StringVar(&c.storageBackend, "storage", STORAGE_ELASTICSEARCH, "Which storage backend to use ('es' or 'plain')")
 	flag.StringVar(&healthCheckInterval, "healthCheckInterval", "10s", "Interval in which to check node health")
+	flag.StringVar(&reloadInterval, "reloadInterval", "0", "Interval in which to check the configuration directories for changes (0 to only reload on SIGHUP)")
+
+	flag.StringVar(&roleLimits, "roleLimits", "", "Maximum number of concurrent jobs on nodes with certain roles, for nodes without their own limit (for example, 'utility=2,db=4')")
+
+	flag.IntVar(&c.maxOutputBytes, "maxOutputBytes", 1024 * 1024, "Maximum number of bytes of output per stream to keep in reports, for jobs without their own limit (0 for no limit)")
+	flag.StringVar(&c.outputDirectory, "outputDirectory", "", "Directory to spool the full output of jobs with 'spool_output' to")
+	flag.StringVar(&c.knownHostsFile, "knownHostsFile", os.Getenv("HOME") + "/.ssh/known_hosts", "known_hosts file to verify the host keys of SSH nodes with")
+	flag.StringVar(&c.sshConfigFile, "sshConfig", "", "OpenSSH client configuration file to resolve SSH node hosts with (for example, '~/.ssh/config')")
+	flag.StringVar(&c.hostKeyStore, "hostKeyStore", "", "File to record the host keys of unknown SSH nodes in on the first connection (trust on first use; empty to reject unknown hosts)")
+
+	flag.StringVar(&c.leaderElection, "leaderElection", "", "Lock backend to use for electing a leader among multiple instances ('file'; empty to disable)")
+	flag.StringVar(&c.leaseFile, "leaseFile", "/var/lib/distcrond/leader.json", "Lease file on a shared filesystem (for 'file' lock backend)")
+	flag.StringVar(&leaseTTL, "leaseTTL", "15s", "Time after which the leadership expires when it is not renewed")
+	flag.StringVar(&c.instanceName, "instanceName", hostname, "Name of this instance in the leader election")
+	flag.StringVar(&c.advertiseAddress, "advertiseAddress", hostname + ":8080", "Address under which other instances can reach this instance's REST API")
 
 	flag.StringVar(&c.esHost, "esHost", "localhost", "Elasticsearch host")
 	flag.IntVar(&c.esPort, "esPort", 9200, "Elasticsearch port")
@@ -106,9 +200,44 @@ func (c *RuntimeConfig) PopulateFromFlags() error {
 		return err
 	}
 
+	if c.reloadInterval, err = time.ParseDuration(reloadInterval); err != nil {
+		return err
+	}
+
+	if c.leaseTTL, err = time.ParseDuration(leaseTTL); err != nil {
+		return err
+	}
+
+	if c.roleLimits, err = parseRoleLimits(roleLimits); err != nil {
+		return err
+	}
+
 	return nil
 }
 
+func parseRoleLimits(value string) (map[string]int, error) {
+	limits := make(map[string]int)
+	if value == "" {
+		return limits, nil
+	}
+
+	for _, entry := range strings.Split(value, ",") {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 {
+			return nil, errors.New(fmt.Sprintf("Invalid role limit '%s', must be 'role=limit'", entry))
+		}
+
+		limit, err := strconv.Atoi(parts[1])
+		if err != nil || limit < 1 {
+			return nil, errors.New(fmt.Sprintf("Invalid role limit '%s', limit must be a positive number", entry))
+		}
+
+		limits[strings.TrimSpace(parts[0])] = limit
+	}
+
+	return limits, nil
+}
+
 func (c *RuntimeConfig) IsValid() error {
 	checkDir := func(dir string, purpose string) error {
 		if _, err := os.Stat(dir); err != nil {
@@ -129,6 +258,22 @@ func (c *RuntimeConfig) IsValid() error {
 		return err
 	}
 
+	if c.maintenanceDirectory != "" {
+		if err := checkDir(c.maintenanceDirectory, "maintenance window directory"); err != nil {
+			return err
+		}
+	}
+
+	if c.outputDirectory != "" {
+		if err := checkDir(c.outputDirectory, "output spool directory"); err != nil {
+			return err
+		}
+	}
+
+	if c.maxOutputBytes < 0 {
+		return errors.New("Max output bytes must not be negative")
+	}
+
 	switch c.storageBackend {
 	case STORAGE_ELASTICSEARCH:
 		if c.esHost != "" {
@@ -142,5 +287,21 @@ func (c *RuntimeConfig) IsValid() error {
 		return errors.New(fmt.Sprintf("Unknown storage backend '%s', must be '" + STORAGE_ELASTICSEARCH + "'", c.storageBackend))
 	}
 
+	switch c.leaderElection {
+	case "":
+	case "file":
+		if c.leaseFile == "" {
+			return errors.New("No lease file specified")
+		}
+		if c.instanceName == "" {
+			return errors.New("No instance name specified")
+		}
+		if c.leaseTTL < time.Second {
+			return errors.New("Lease TTL must be at least one second")
+		}
+	default:
+		return errors.New(fmt.Sprintf("Unknown lock backend '%s', must be 'file'", c.leaderElection))
+	}
+
 	return nil
 }
@@ -3,42 +3,101 @@ package container
 import (
 	"errors"
 	"fmt"
+	"reflect"
+	"sync"
 	"github.com/martin-helmich/distcrond/domain"
 )
 
 type JobContainer struct {
-	jobs       []domain.Job
+	jobs       []*domain.Job
 	jobsByName map[string]*domain.Job
+	lock       sync.RWMutex
 }
 
 func NewJobContainer(initialCapacity int) *JobContainer {
 	container := new(JobContainer)
-	container.jobs = make([]domain.Job, 0, initialCapacity)
+	container.jobs = make([]*domain.Job, 0, initialCapacity)
 	container.jobsByName = make(map[string]*domain.Job)
 	return container
 }
 
 func (c *JobContainer) AddJob(job domain.Job) {
-	c.jobs = append(c.jobs, job)
-	c.jobsByName[job.Name] = &c.jobs[len(c.jobs)-1]
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.jobs = append(c.jobs, &job)
+	c.jobsByName[job.Name] = &job
 }
 
 func (c *JobContainer) Count() int {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
 	return len(c.jobs)
 }
 
-func (c *JobContainer) All() []domain.Job {
-	return c.jobs
+// Returns a snapshot of all jobs. Use this instead of Count() and Get() when
+// the container might be modified concurrently.
+func (c *JobContainer) All() []*domain.Job {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	jobs := make([]*domain.Job, len(c.jobs))
+	copy(jobs, c.jobs)
+	return jobs
 }
 
 func (c *JobContainer) Get(i int) *domain.Job {
-	return &c.jobs[i]
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return c.jobs[i]
 }
 
 func (c *JobContainer) JobByName(n string) (*domain.Job, error) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
 	if job, ok := c.jobsByName[n]; !ok {
 		return nil, errors.New(fmt.Sprintf("No job with name '%s' is known", n))
 	} else {
 		return job, nil
 	}
 }
+
+// Replaces the container's jobs with a new set of jobs. Jobs whose definition
+// did not change are kept as they are. Changed jobs are replaced, but inherit
+// the runtime state of their previous version. Returns the names of the
+// added, changed and removed jobs.
+func (c *JobContainer) Update(jobs []*domain.Job) (added []string, changed []string, removed []string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	updatedJobs := make([]*domain.Job, 0, len(jobs))
+	updatedJobsByName := make(map[string]*domain.Job)
+
+	for _, job := range jobs {
+		if previous, ok := c.jobsByName[job.Name]; !ok {
+			added = append(added, job.Name)
+		} else if reflect.DeepEqual(previous.Definition, job.Definition) && reflect.DeepEqual(previous.Downstream, job.Downstream) {
+			job = previous
+		} else {
+			job.RestoreState(previous.State())
+			changed = append(changed, job.Name)
+		}
+
+		updatedJobs = append(updatedJobs, job)
+		updatedJobsByName[job.Name] = job
+	}
+
+	for _, job := range c.jobs {
+		if _, ok := updatedJobsByName[job.Name]; !ok {
+			removed = append(removed, job.Name)
+		}
+	}
+
+	c.jobs = updatedJobs
+	c.jobsByName = updatedJobsByName
+
+	return
+}
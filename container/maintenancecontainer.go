@@ -0,0 +1,126 @@
+package container
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+	"github.com/martin-helmich/distcrond/domain"
+)
+
+type MaintenanceContainer struct {
+	windows map[string]*domain.MaintenanceWindow
+	lock sync.RWMutex
+}
+
+func NewMaintenanceContainer() *MaintenanceContainer {
+	return &MaintenanceContainer{
+		windows: make(map[string]*domain.MaintenanceWindow),
+	}
+}
+
+// Adds a window, replacing any window with the same ID.
+func (c *MaintenanceContainer) AddWindow(window *domain.MaintenanceWindow) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.windows[window.Id] = window
+}
+
+func (c *MaintenanceContainer) RemoveWindow(id string) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if _, ok := c.windows[id]; !ok {
+		return errors.New(fmt.Sprintf("No maintenance window with ID %s is known", id))
+	}
+
+	delete(c.windows, id)
+	return nil
+}
+
+func (c *MaintenanceContainer) WindowById(id string) (*domain.MaintenanceWindow, error) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	if window, ok := c.windows[id]; ok {
+		return window, nil
+	} else {
+		return nil, errors.New(fmt.Sprintf("No maintenance window with ID %s is known", id))
+	}
+}
+
+// Replaces all windows with a new set of windows. Windows that are already
+// over are left out.
+func (c *MaintenanceContainer) Update(windows []*domain.MaintenanceWindow) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	now := time.Now()
+
+	c.windows = make(map[string]*domain.MaintenanceWindow)
+	for _, window := range windows {
+		if !window.IsExpired(now) {
+			c.windows[window.Id] = window
+		}
+	}
+}
+
+// Removes the one-time windows that are over at the given time, and returns
+// their IDs.
+func (c *MaintenanceContainer) RemoveExpired(t time.Time) []string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	removed := make([]string, 0)
+	for id, window := range c.windows {
+		if window.IsExpired(t) {
+			delete(c.windows, id)
+			removed = append(removed, id)
+		}
+	}
+
+	sort.Strings(removed)
+	return removed
+}
+
+// Returns a snapshot of all windows, sorted by ID.
+func (c *MaintenanceContainer) All() []*domain.MaintenanceWindow {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	ids := make([]string, 0, len(c.windows))
+	for id := range c.windows {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	windows := make([]*domain.MaintenanceWindow, len(ids))
+	for i, id := range ids {
+		windows[i] = c.windows[id]
+	}
+	return windows
+}
+
+// Returns the window that prevents the job from being run at the given time,
+// or nil if there is none.
+func (c *MaintenanceContainer) ActiveWindowForJob(job *domain.Job, t time.Time) *domain.MaintenanceWindow {
+	for _, window := range c.All() {
+		if window.IsJobLevel() && window.AppliesToJob(job) && window.IsActive(t) {
+			return window
+		}
+	}
+	return nil
+}
+
+// Returns the window that prevents the job from being run on the node at the
+// given time, or nil if there is none.
+func (c *MaintenanceContainer) ActiveWindowForNode(node *domain.Node, job *domain.Job, t time.Time) *domain.MaintenanceWindow {
+	for _, window := range c.All() {
+		if !window.IsJobLevel() && window.AppliesToNode(node) && window.AppliesToJob(job) && window.IsActive(t) {
+			return window
+		}
+	}
+	return nil
+}
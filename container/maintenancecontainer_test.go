@@ -0,0 +1,26 @@
+package container
+
+import (
+	"testing"
+	"time"
+	"github.com/martin-helmich/distcrond/domain"
+)
+
+func TestRemoveExpiredKeepsRecurringAndFutureWindows(t *testing.T) {
+	now := time.Now()
+
+	c := NewMaintenanceContainer()
+	c.AddWindow(&domain.MaintenanceWindow{Id: "over", Start: now.Add(-2 * time.Hour), End: now.Add(-time.Hour)})
+	c.AddWindow(&domain.MaintenanceWindow{Id: "active", Start: now.Add(-time.Hour), End: now.Add(time.Hour)})
+	c.AddWindow(&domain.MaintenanceWindow{Id: "sundays", Weekdays: []time.Weekday{time.Sunday}, Location: time.UTC})
+
+	removed := c.RemoveExpired(now)
+
+	if len(removed) != 1 || removed[0] != "over" {
+		t.Errorf("Expected only window 'over' to be removed, got %v", removed)
+	}
+
+	if all := c.All(); len(all) != 2 || all[0].Id != "active" || all[1].Id != "sundays" {
+		t.Errorf("Unexpected remaining windows %v", all)
+	}
+}
@@ -5,44 +5,128 @@ import (
 	"fmt"
 	"github.com/martin-helmich/distcrond/domain"
 	"github.com/martin-helmich/distcrond/logging"
+	"math"
 	"math/rand"
+	"reflect"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type NodeContainer struct {
-	nodes       []domain.Node
+	nodes       []*domain.Node
 	nodesByName map[string]*domain.Node
 	nodesByRole map[string][]*domain.Node
+	maintenance *MaintenanceContainer
+	lock        sync.RWMutex
+
+	// Number of runs per job, for round robin selection
+	rounds      map[string]int
+	roundsLock  sync.Mutex
 }
 
 func NewNodeContainer(initialCapacity int) *NodeContainer {
 	container := new(NodeContainer)
-	container.nodes = make([]domain.Node, 0, initialCapacity)
+	container.nodes = make([]*domain.Node, 0, initialCapacity)
 	container.nodesByName = make(map[string]*domain.Node)
 	container.nodesByRole = make(map[string][]*domain.Node)
+	container.rounds = make(map[string]int)
 	return container
 }
 
+// Excludes nodes that are in maintenance from running jobs.
+func (c *NodeContainer) SetMaintenanceWindows(maintenance *MaintenanceContainer) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.maintenance = maintenance
+}
+
 func (c *NodeContainer) AddNode(node domain.Node) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.addNode(&node)
+}
+
+func (c *NodeContainer) addNode(node *domain.Node) {
 	c.nodes = append(c.nodes, node)
-	c.nodesByName[node.Name] = &c.nodes[len(c.nodes)-1]
+	c.nodesByName[node.Name] = node
 
 	for _, role := range node.Roles {
 		if _, ok := c.nodesByRole[role]; ok == false {
 			c.nodesByRole[role] = make([]*domain.Node, 0, 3)
 		}
-		c.nodesByRole[role] = append(c.nodesByRole[role], &c.nodes[len(c.nodes)-1])
+		c.nodesByRole[role] = append(c.nodesByRole[role], node)
 	}
 }
 
 func (c *NodeContainer) Count() int {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
 	return len(c.nodes)
 }
 
+// Returns a snapshot of all nodes. Use this instead of Count() and Get() when
+// the container might be modified concurrently.
+func (c *NodeContainer) All() []*domain.Node {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	nodes := make([]*domain.Node, len(c.nodes))
+	copy(nodes, c.nodes)
+	return nodes
+}
+
 func (c *NodeContainer) Get(i int) *domain.Node {
-	return &c.nodes[i]
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return c.nodes[i]
+}
+
+// Replaces the container's nodes with a new set of nodes. Nodes whose
+// definition did not change are kept as they are. Returns the names of the
+// added and changed nodes, and the nodes that were removed or replaced (and
+// that might still be running jobs).
+func (c *NodeContainer) Update(nodes []*domain.Node) (added []string, changed []string, retired []*domain.Node) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	previousNodes := c.nodesByName
+
+	c.nodes = make([]*domain.Node, 0, len(nodes))
+	c.nodesByName = make(map[string]*domain.Node)
+	c.nodesByRole = make(map[string][]*domain.Node)
+
+	for _, node := range nodes {
+		if previous, ok := previousNodes[node.Name]; !ok {
+			added = append(added, node.Name)
+		} else if reflect.DeepEqual(previous.Definition, node.Definition) {
+			node = previous
+		} else {
+			changed = append(changed, node.Name)
+			retired = append(retired, previous)
+		}
+
+		c.addNode(node)
+	}
+
+	for name, previous := range previousNodes {
+		if _, ok := c.nodesByName[name]; !ok {
+			retired = append(retired, previous)
+		}
+	}
+
+	return
 }
 
 func (c *NodeContainer) NodeByName(name string) (*domain.Node, error) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
 	if node, ok := c.nodesByName[name]; ok {
 		return node, nil
 	} else {
@@ -50,29 +134,119 @@ func (c *NodeContainer) NodeByName(name string) (*domain.Node, error) {
 	}
 }
 
+// Returns the healthy nodes that a job with the "any" or "some" policy may run on, in
+// the order in which they should be tried according to the job's selection
+// strategy.
 func (c *NodeContainer) NodeCandidatesForJob(job *domain.Job) []*domain.Node {
 	nodes := c.potentialNodesForJob(job, true)
 
-	// Fisher-Yates shuffle
+	switch job.Policy.Selection {
+	case domain.SELECTION_ROUND_ROBIN:
+		if len(nodes) > 0 {
+			offset := c.nextRound(job) % len(nodes)
+			rotated := make([]*domain.Node, 0, len(nodes))
+			nodes = append(append(rotated, nodes[offset:]...), nodes[:offset]...)
+		}
+
+	case domain.SELECTION_LEAST_RUNNING:
+		// Shuffle first, so that ties are broken randomly
+		shuffleNodes(nodes)
+		sort.Stable(byRunningJobs(nodes))
+
+	case domain.SELECTION_WEIGHTED:
+		weightedShuffleNodes(nodes)
+
+	case domain.SELECTION_STICKY:
+		shuffleNodes(nodes)
+
+		last := job.GetLastNode()
+		for i, node := range nodes {
+			if node.Name == last {
+				nodes[0], nodes[i] = nodes[i], nodes[0]
+				break
+			}
+		}
+
+	default:
+		shuffleNodes(nodes)
+	}
+
+	return nodes
+}
+
+func (c *NodeContainer) nextRound(job *domain.Job) int {
+	c.roundsLock.Lock()
+	defer c.roundsLock.Unlock()
+
+	round := c.rounds[job.Name]
+	c.rounds[job.Name] = round + 1
+	return round
+}
+
+// Fisher-Yates shuffle
+func shuffleNodes(nodes []*domain.Node) {
 	for i, _ := range nodes {
 		j := rand.Intn(i + 1)
 		nodes[i], nodes[j] = nodes[j], nodes[i]
 	}
+}
 
-	return nodes
+// Orders the nodes randomly, with the probability of a node coming first
+// being proportional to its weight (Efraimidis-Spirakis).
+func weightedShuffleNodes(nodes []*domain.Node) {
+	keys := make(map[*domain.Node]float64, len(nodes))
+	for _, node := range nodes {
+		weight := node.Weight
+		if weight < 1 {
+			weight = 1
+		}
+		keys[node] = math.Pow(rand.Float64(), 1 / float64(weight))
+	}
+
+	sort.Sort(byKey{nodes, keys})
+}
+
+type byRunningJobs []*domain.Node
+
+func (n byRunningJobs) Len() int { return len(n) }
+func (n byRunningJobs) Swap(i, j int) { n[i], n[j] = n[j], n[i] }
+func (n byRunningJobs) Less(i, j int) bool {
+	return atomic.LoadInt32(&n[i].RunningJobs) < atomic.LoadInt32(&n[j].RunningJobs)
 }
 
+type byKey struct {
+	nodes []*domain.Node
+	keys map[*domain.Node]float64
+}
+
+func (n byKey) Len() int { return len(n.nodes) }
+func (n byKey) Swap(i, j int) { n.nodes[i], n.nodes[j] = n.nodes[j], n.nodes[i] }
+func (n byKey) Less(i, j int) bool { return n.keys[n.nodes[i]] > n.keys[n.nodes[j]] }
+
 func (c *NodeContainer) NodesForJob(job *domain.Job) []*domain.Node {
 	switch job.Policy.Hosts {
 	case domain.POLICY_ALL:
 		return c.potentialNodesForJob(job, false)
 
 	case domain.POLICY_ANY:
-		potentialNodes := c.potentialNodesForJob(job, true)
+		potentialNodes := c.NodeCandidatesForJob(job)
 		logging.Debug("Found %d potential nodes for job %s: %s", len(potentialNodes), job.Name, potentialNodes)
 
-		idx := rand.Int() % len(potentialNodes)
-		return potentialNodes[idx : idx+1]
+		if len(potentialNodes) == 0 {
+			return potentialNodes
+		}
+
+		return potentialNodes[:1]
+
+	case domain.POLICY_SOME:
+		count := c.NodeCountForJob(job)
+		potentialNodes := c.NodeCandidatesForJob(job)
+
+		if len(potentialNodes) < count {
+			return potentialNodes
+		}
+
+		return potentialNodes[:count]
 
 	default:
 		logging.Error("Invalid job policy: %s", job.Policy.Hosts)
@@ -80,6 +254,12 @@ func (c *NodeContainer) NodesForJob(job *domain.Job) []*domain.Node {
 	}
 }
 
+// Returns the number of nodes that a job should run on. For percentages, all
+// nodes that match the job's policy are counted, regardless of their status.
+func (c *NodeContainer) NodeCountForJob(job *domain.Job) int {
+	return job.Policy.NodeCount(len(c.potentialNodesForJob(job, false)))
+}
+
 func (c *NodeContainer) NodesWithStatus(status domain.NodeStatus) []*domain.Node {
 	return c.NodesByFilter(func(n *domain.Node) bool {
 		return n.Status == status
@@ -87,11 +267,14 @@ func (c *NodeContainer) NodesWithStatus(status domain.NodeStatus) []*domain.Node
 }
 
 func (c *NodeContainer) NodesByFilter(filter (func(*domain.Node) bool)) []*domain.Node {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
 	var nodes []*domain.Node = make([]*domain.Node, 0, len(c.nodes))
 
 	for _, node := range c.nodes {
-		if filter(&node) {
-			nodes = append(nodes, &node)
+		if filter(node) {
+			nodes = append(nodes, node)
 		}
 	}
 
@@ -99,6 +282,9 @@ func (c *NodeContainer) NodesByFilter(filter (func(*domain.Node) bool)) []*domai
 }
 
 func (c *NodeContainer) potentialNodesForJob(job *domain.Job, onlyHealthyNodes bool) []*domain.Node {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
 	var filter func(*domain.Node) bool = func(_ *domain.Node) bool { return true }
 	var nodes []*domain.Node = make([]*domain.Node, 0, len(c.nodes))
 
@@ -108,6 +294,14 @@ func (c *NodeContainer) potentialNodesForJob(job *domain.Job, onlyHealthyNodes b
 		}
 	}
 
+	if c.maintenance != nil {
+		now := time.Now()
+		statusFilter := filter
+		filter = func(node *domain.Node) bool {
+			return c.maintenance.ActiveWindowForNode(node, job, now) == nil && statusFilter(node)
+		}
+	}
+
 	if len(job.Policy.HostList) > 0 {
 		for _, name := range job.Policy.HostList {
 			if node, ok := c.nodesByName[name]; ok && filter(node) {
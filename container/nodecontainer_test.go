@@ -231,3 +231,124 @@ func TestNodesForJobReturnsUniformPointerToNode(t *testing.T) {
 
 	assertThat(selectedNodes[0] == realNode, "Different pointers returned", t)
 }
+
+func TestNodesForJobLeavesOutNodesInMaintenance(t *testing.T) {
+	c := NewNodeContainer(3)
+	c.AddNode(domain.Node{Name: "n1", Roles: []string{"db"}})
+	c.AddNode(domain.Node{Name: "n2", Roles: []string{"db"}})
+	c.AddNode(domain.Node{Name: "n3", Roles: []string{"db", "backup"}})
+
+	window, _ := domain.NewMaintenanceWindowFromJson("backup-maintenance", domain.MaintenanceWindowJson{
+		Roles: []string{"backup"},
+		Start: "2000-01-01 00:00",
+		End: "2100-01-01 00:00",
+	})
+
+	maintenance := NewMaintenanceContainer()
+	maintenance.AddWindow(window)
+	c.SetMaintenanceWindows(maintenance)
+
+	job := new(domain.Job)
+	job.Policy.Hosts = domain.POLICY_ALL
+	job.Policy.Roles = []string{"db"}
+
+	selectedNodes := c.NodesForJob(job)
+
+	assertThat(len(selectedNodes) == 2, "Wrong node count", t)
+	for _, node := range selectedNodes {
+		assertThat(node.Name != "n3", "Node in maintenance was selected", t)
+	}
+}
+
+func TestRoundRobinSelectionRotatesNodes(t *testing.T) {
+	c := NewNodeContainer(3)
+	c.AddNode(domain.Node{Name: "n1", Roles: []string{"web"}})
+	c.AddNode(domain.Node{Name: "n2", Roles: []string{"web"}})
+	c.AddNode(domain.Node{Name: "n3", Roles: []string{"web"}})
+
+	job := new(domain.Job)
+	job.Policy.Hosts = domain.POLICY_ANY
+	job.Policy.HostList = []string{"n1", "n2", "n3"}
+	job.Policy.Selection = domain.SELECTION_ROUND_ROBIN
+
+	for _, expected := range []string{"n1", "n2", "n3", "n1"} {
+		candidates := c.NodeCandidatesForJob(job)
+		assertThat(len(candidates) == 3, "Wrong candidate count", t)
+		assertThat(candidates[0].Name == expected, "Expected " + expected + ", got " + candidates[0].Name, t)
+	}
+}
+
+func TestLeastRunningSelectionPrefersIdleNodes(t *testing.T) {
+	c := NewNodeContainer(3)
+	c.AddNode(domain.Node{Name: "n1", Roles: []string{"web"}, RunningJobs: 2})
+	c.AddNode(domain.Node{Name: "n2", Roles: []string{"web"}, RunningJobs: 0})
+	c.AddNode(domain.Node{Name: "n3", Roles: []string{"web"}, RunningJobs: 1})
+
+	job := new(domain.Job)
+	job.Policy.Hosts = domain.POLICY_ANY
+	job.Policy.Roles = []string{"web"}
+	job.Policy.Selection = domain.SELECTION_LEAST_RUNNING
+
+	candidates := c.NodeCandidatesForJob(job)
+
+	assertThat(candidates[0].Name == "n2", "Expected idle node first", t)
+	assertThat(candidates[1].Name == "n3", "Expected node with one job second", t)
+	assertThat(candidates[2].Name == "n1", "Expected busiest node last", t)
+}
+
+func TestWeightedSelectionReturnsAllNodes(t *testing.T) {
+	c := NewNodeContainer(2)
+	c.AddNode(domain.Node{Name: "n1", Roles: []string{"web"}, Weight: 10})
+	c.AddNode(domain.Node{Name: "n2", Roles: []string{"web"}, Weight: 1})
+
+	job := new(domain.Job)
+	job.Policy.Hosts = domain.POLICY_ANY
+	job.Policy.Roles = []string{"web"}
+	job.Policy.Selection = domain.SELECTION_WEIGHTED
+
+	candidates := c.NodeCandidatesForJob(job)
+
+	assertThat(len(candidates) == 2, "Wrong candidate count", t)
+	assertThat(candidates[0] != candidates[1], "Node returned twice", t)
+}
+
+func TestStickySelectionPrefersLastNodeUnlessDown(t *testing.T) {
+	c := NewNodeContainer(3)
+	c.AddNode(domain.Node{Name: "n1", Roles: []string{"web"}})
+	c.AddNode(domain.Node{Name: "n2", Roles: []string{"web"}})
+	c.AddNode(domain.Node{Name: "n3", Roles: []string{"web"}})
+
+	job := new(domain.Job)
+	job.Policy.Hosts = domain.POLICY_ANY
+	job.Policy.Roles = []string{"web"}
+	job.Policy.Selection = domain.SELECTION_STICKY
+	job.SetLastNode("n2")
+
+	for i := 0; i < 10; i ++ {
+		assertThat(c.NodeCandidatesForJob(job)[0].Name == "n2", "Expected last node first", t)
+	}
+
+	node, _ := c.NodeByName("n2")
+	node.Status = domain.STATUS_DOWN
+
+	candidates := c.NodeCandidatesForJob(job)
+	assertThat(len(candidates) == 2, "Wrong candidate count", t)
+	assertThat(candidates[0].Name != "n2", "Node that is down was selected", t)
+}
+
+func TestNodesForJobReturnsPercentageOfNodes(t *testing.T) {
+	c := NewNodeContainer(5)
+	for _, name := range []string{"n1", "n2", "n3", "n4", "n5"} {
+		c.AddNode(domain.Node{Name: name, Roles: []string{"web"}})
+	}
+
+	job := new(domain.Job)
+	job.Policy.Hosts = domain.POLICY_SOME
+	job.Policy.Roles = []string{"web"}
+	job.Policy.Percent = 50
+
+	selectedNodes := c.NodesForJob(job)
+
+	assertThat(len(selectedNodes) == 3, "Wrong node count", t)
+	assertThat(selectedNodes[0] != selectedNodes[1] && selectedNodes[1] != selectedNodes[2] && selectedNodes[0] != selectedNodes[2], "Node returned twice", t)
+}
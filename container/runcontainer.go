@@ -0,0 +1,67 @@
+package container
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"github.com/martin-helmich/distcrond/domain"
+)
+
+// Keeps track of the most recent runs, so that their status can be queried
+// while (and shortly after) they are executed.
+type RunContainer struct {
+	runs       []*domain.RunReport
+	runsById   map[string]*domain.RunReport
+	capacity   int
+	lock       sync.RWMutex
+}
+
+func NewRunContainer(capacity int) *RunContainer {
+	container := new(RunContainer)
+	container.runs = make([]*domain.RunReport, 0, capacity)
+	container.runsById = make(map[string]*domain.RunReport)
+	container.capacity = capacity
+	return container
+}
+
+// Adds a run. When the container is full, the oldest finished run is removed.
+// Adding the same run twice has no effect.
+func (c *RunContainer) AddRun(run *domain.RunReport) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if _, known := c.runsById[run.Id]; known {
+		return
+	}
+
+	if len(c.runs) >= c.capacity {
+		for i, old := range c.runs {
+			if old.Phase() == domain.RUN_FINISHED {
+				delete(c.runsById, old.Id)
+				c.runs = append(c.runs[:i], c.runs[i+1:]...)
+				break
+			}
+		}
+	}
+
+	c.runs = append(c.runs, run)
+	c.runsById[run.Id] = run
+}
+
+func (c *RunContainer) Count() int {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return len(c.runs)
+}
+
+func (c *RunContainer) RunById(id string) (*domain.RunReport, error) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	if run, ok := c.runsById[id]; !ok {
+		return nil, errors.New(fmt.Sprintf("No run with ID '%s' is known", id))
+	} else {
+		return run, nil
+	}
+}
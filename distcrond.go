@@ -11,8 +11,10 @@ import (
 	"github.com/martin-helmich/distcrond/logging"
 	"github.com/martin-helmich/distcrond/storage"
 	"github.com/martin-helmich/distcrond/server"
+	"github.com/martin-helmich/distcrond/election"
 	"runtime/pprof"
 	"fmt"
+	"syscall"
 )
 
 var runtimeConfig *RuntimeConfig
@@ -63,7 +65,7 @@ func main() {
 	nodesLoaded, jobsLoaded := make(chan bool), make(chan bool)
 
 	go func() {
-		nodeReader := reader.NewNodeReader(nodeContainer)
+		nodeReader := reader.NewNodeReader(runtimeConfig, nodeContainer)
 		if err := nodeReader.ReadFromDirectory(runtimeConfig.NodesDirectory()); err != nil {
 			log.Fatal(err)
 		}
@@ -81,6 +83,16 @@ func main() {
 	<-nodesLoaded
 	<-jobsLoaded
 
+	maintenanceContainer := container.NewMaintenanceContainer()
+	if runtimeConfig.MaintenanceDirectory() != "" {
+		maintenanceReader := reader.NewMaintenanceReader(maintenanceContainer)
+		if err := maintenanceReader.ReadFromDirectory(runtimeConfig.MaintenanceDirectory()); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	nodeContainer.SetMaintenanceWindows(maintenanceContainer)
+
 	storageBackend, err := storage.BuildStorageBackend(runtimeConfig)
 	if err != nil {
 		log.Fatal(err)
@@ -94,18 +106,57 @@ func main() {
 
 	healthChecker := runner.NewHealthChecker(runtimeConfig)
 	jobRunner := runner.NewDispatchingRunner(nodeContainer, storageBackend, healthChecker)
-	jobScheduler := scheduler.NewScheduler(jobContainer, nodeContainer, jobRunner)
-	go jobScheduler.Run()
+	runContainer := container.NewRunContainer(1000)
+
+	jobScheduler := scheduler.NewScheduler(jobContainer, nodeContainer, maintenanceContainer, jobRunner, storageBackend, runContainer)
+
+	var elector *election.Elector
+	if runtimeConfig.LeaderElectionEnabled() {
+		lockBackend, err := election.BuildLockBackend(runtimeConfig)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		elector = election.NewElector(lockBackend, runtimeConfig.InstanceName(), runtimeConfig.AdvertiseAddress(), runtimeConfig.LeaseTTL())
+		elector.OnElected = jobScheduler.Start
+		elector.OnDemoted = jobScheduler.Stop
 
-	restServer := server.NewRestServer(8080, nodeContainer, jobContainer, storageBackend, logging.GetLogger("restapi"))
+		go jobScheduler.RunStandby()
+		go elector.Run()
+	} else {
+		go jobScheduler.Run()
+	}
+
+	restServer := server.NewRestServer(8080, nodeContainer, jobContainer, runContainer, maintenanceContainer, runtimeConfig.MaintenanceDirectory(), jobScheduler, elector, storageBackend, logging.GetLogger("restapi"))
 	go restServer.Start()
 
+	reloader := NewReloader(runtimeConfig, jobContainer, nodeContainer, maintenanceContainer, jobScheduler, storageBackend)
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	go func() {
+		for range hup {
+			log.Notice("Received SIGHUP")
+			if err := reloader.Reload(); err != nil {
+				log.Error("Could not reload configuration: %s", err)
+			}
+		}
+	}()
+
+	if runtimeConfig.ReloadInterval() > 0 {
+		go reloader.Watch(runtimeConfig.ReloadInterval())
+	}
+
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt)
 
 	go func() {
 		<-c
 		log.Notice("Received SIGINT")
+		if elector != nil {
+			elector.Stop()
+		}
 		jobScheduler.Abort()
 	}()
 
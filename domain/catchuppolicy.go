@@ -0,0 +1,101 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	"github.com/robfig/cron"
+)
+
+const (
+	CATCHUP_NONE = "none"
+	CATCHUP_LATEST = "latest"
+	CATCHUP_ALL = "all"
+)
+
+const DEFAULT_CATCHUP_LIMIT = 10
+
+// Describes how to deal with runs that were missed while distcrond was not
+// running:
+//
+// - "none" drops missed runs
+// - "latest" replays only the most recent missed run
+// - "all" replays all missed runs, but not more than "Limit" (the most recent
+//   ones are kept)
+type CatchUpPolicy struct {
+	Mode string
+	Limit int
+}
+
+func NewCatchUpPolicyFromJson(json JobJson) (CatchUpPolicy, error) {
+	policy := CatchUpPolicy{
+		Mode: json.CatchUp,
+		Limit: json.CatchUpLimit,
+	}
+
+	if len(policy.Mode) == 0 {
+		policy.Mode = CATCHUP_NONE
+	}
+
+	if policy.Limit == 0 {
+		policy.Limit = DEFAULT_CATCHUP_LIMIT
+	}
+
+	return policy, nil
+}
+
+func (p CatchUpPolicy) IsValid() error {
+	switch p.Mode {
+	case CATCHUP_NONE, CATCHUP_LATEST, CATCHUP_ALL:
+	default:
+		return errors.New(fmt.Sprintf("'CatchUp' must be one of '%s', '%s' or '%s'", CATCHUP_NONE, CATCHUP_LATEST, CATCHUP_ALL))
+	}
+
+	if p.Limit < 1 {
+		return errors.New("'CatchUpLimit' must be at least 1")
+	}
+
+	return nil
+}
+
+// Computes the scheduled times of all runs between "last" and "now" that
+// should be replayed. As only the most recent runs are replayed, the runs are
+// looked for in a window before "now" that is widened until it contains
+// enough runs or reaches back to "last"; this way, frequent jobs that were not
+// run for a long time do not take ages to catch up on.
+func (p CatchUpPolicy) MissedRuns(schedule cron.Schedule, last time.Time, now time.Time) []time.Time {
+	if p.Mode == CATCHUP_NONE || last.IsZero() || schedule == nil {
+		return nil
+	}
+
+	limit := p.Limit
+	if p.Mode == CATCHUP_LATEST {
+		limit = 1
+	}
+
+	for window := time.Minute; ; window *= 2 {
+		start := now.Add(-window)
+		if window >= now.Sub(last) || window > 1 << 61 {
+			start = last
+		}
+
+		missed := lastRunsBetween(schedule, start, now, limit)
+		if len(missed) == limit || start == last {
+			return missed
+		}
+	}
+}
+
+// Returns the last "limit" scheduled times after "start" and before "end".
+func lastRunsBetween(schedule cron.Schedule, start time.Time, end time.Time, limit int) []time.Time {
+	missed := make([]time.Time, 0, limit)
+	for next := schedule.Next(start); !next.IsZero() && next.Before(end); next = schedule.Next(next) {
+		if len(missed) == limit {
+			missed = append(missed[1:], next)
+		} else {
+			missed = append(missed, next)
+		}
+	}
+
+	return missed
+}
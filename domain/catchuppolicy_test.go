@@ -0,0 +1,50 @@
+package domain
+
+import (
+	"testing"
+	"time"
+	"github.com/robfig/cron"
+)
+
+func TestMissedRunsReturnsMostRecentRunsOfFrequentJobs(t *testing.T) {
+	policy := CatchUpPolicy{Mode: CATCHUP_ALL, Limit: 3}
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+	started := time.Now()
+	missed := policy.MissedRuns(cron.Every(time.Second), now.Add(-30 * 24 * time.Hour), now)
+
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Errorf("Computing missed runs took %s", elapsed)
+	}
+
+	expected := []time.Time{now.Add(-3 * time.Second), now.Add(-2 * time.Second), now.Add(-time.Second)}
+	if len(missed) != len(expected) {
+		t.Fatalf("Expected %d runs, got %v", len(expected), missed)
+	}
+
+	for i := range expected {
+		if !missed[i].Equal(expected[i]) {
+			t.Errorf("Expected run %d at %s, got %s", i, expected[i], missed[i])
+		}
+	}
+}
+
+func TestMissedRunsReachesBackToLastRun(t *testing.T) {
+	policy := CatchUpPolicy{Mode: CATCHUP_ALL, Limit: 10}
+	now := time.Date(2026, 10, 18, 12, 30, 0, 0, time.UTC)
+	last := now.Add(-3 * 24 * time.Hour)
+
+	schedule, err := cron.Parse("0 0 6 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	missed := policy.MissedRuns(schedule, last, now)
+	if len(missed) != 3 {
+		t.Fatalf("Expected 3 runs, got %v", missed)
+	}
+
+	if !missed[0].Equal(time.Date(2026, 10, 16, 6, 0, 0, 0, time.UTC)) || !missed[2].Equal(time.Date(2026, 10, 18, 6, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected runs %v", missed)
+	}
+}
@@ -0,0 +1,65 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	CONCURRENCY_FORBID = "forbid"
+	CONCURRENCY_QUEUE = "queue"
+	CONCURRENCY_ALLOW = "allow"
+	CONCURRENCY_REPLACE = "replace"
+)
+
+// Describes what happens when a job is due while a previous run of the same
+// job has not completed yet:
+//
+// - "forbid" skips the new run
+// - "queue" starts the new run after the previous one has completed, with at
+//   most "Limit" runs waiting
+// - "allow" runs up to "Limit" runs in parallel
+// - "replace" cancels the previous run and starts the new one
+type ConcurrencyPolicy struct {
+	Mode string
+	Limit int
+}
+
+func NewConcurrencyPolicyFromJson(json JobJson) (ConcurrencyPolicy, error) {
+	policy := ConcurrencyPolicy{
+		Mode: json.ConcurrencyPolicy,
+		Limit: json.ConcurrencyLimit,
+	}
+
+	if len(policy.Mode) == 0 {
+		policy.Mode = CONCURRENCY_QUEUE
+	}
+
+	if policy.Limit == 0 {
+		policy.Limit = 1
+	}
+
+	return policy, nil
+}
+
+func (p ConcurrencyPolicy) IsValid() error {
+	switch p.Mode {
+	case CONCURRENCY_FORBID, CONCURRENCY_QUEUE, CONCURRENCY_ALLOW, CONCURRENCY_REPLACE:
+	default:
+		return errors.New(fmt.Sprintf("'ConcurrencyPolicy' must be one of '%s', '%s', '%s' or '%s'", CONCURRENCY_FORBID, CONCURRENCY_QUEUE, CONCURRENCY_ALLOW, CONCURRENCY_REPLACE))
+	}
+
+	if p.Limit < 1 {
+		return errors.New("'ConcurrencyLimit' must be at least 1")
+	}
+
+	return nil
+}
+
+// The number of runs that may be active at the same time.
+func (p ConcurrencyPolicy) Slots() int {
+	if p.Mode == CONCURRENCY_ALLOW {
+		return p.Limit
+	}
+	return 1
+}
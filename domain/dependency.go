@@ -0,0 +1,61 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	TRIGGER_SUCCESS = "success"
+	TRIGGER_FAILURE = "failure"
+	TRIGGER_COMPLETION = "completion"
+)
+
+// Describes the jobs that a job depends on. When a run of one of these
+// upstream jobs is finished, the job is triggered depending on the outcome of
+// that run:
+//
+// - "success" triggers the job when the upstream run was successful
+// - "failure" triggers the job when the upstream run failed
+// - "completion" triggers the job in both cases
+//
+// Skipped and cancelled runs never trigger downstream jobs.
+type Dependency struct {
+	Upstream []string
+	Trigger string
+}
+
+func NewDependencyFromJson(json JobJson) (Dependency, error) {
+	dependency := Dependency{
+		Upstream: json.DependsOn,
+		Trigger: json.Trigger,
+	}
+
+	if len(dependency.Trigger) == 0 {
+		dependency.Trigger = TRIGGER_SUCCESS
+	}
+
+	return dependency, nil
+}
+
+func (d Dependency) IsValid() error {
+	switch d.Trigger {
+	case TRIGGER_SUCCESS, TRIGGER_FAILURE, TRIGGER_COMPLETION:
+	default:
+		return errors.New(fmt.Sprintf("'Trigger' must be one of '%s', '%s' or '%s'", TRIGGER_SUCCESS, TRIGGER_FAILURE, TRIGGER_COMPLETION))
+	}
+
+	return nil
+}
+
+// Determines whether a finished upstream run triggers the job.
+func (d Dependency) TriggeredBy(report *RunReport) bool {
+	switch report.Status() {
+	case REPORT_SUCCESS:
+		return d.Trigger == TRIGGER_SUCCESS || d.Trigger == TRIGGER_COMPLETION
+	case REPORT_FAILED:
+		return d.Trigger == TRIGGER_FAILURE || d.Trigger == TRIGGER_COMPLETION
+	}
+
+	return false
+}
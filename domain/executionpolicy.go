@@ -1,40 +1,107 @@
 package domain
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 const (
 	POLICY_ALL = "all"
 	POLICY_ANY = "any"
+	POLICY_SOME = "some"
+)
+
+// Strategies for choosing nodes for jobs with the "any" or "some" policy
+const (
+	SELECTION_RANDOM = "random"
+	SELECTION_ROUND_ROBIN = "round_robin"
+	SELECTION_LEAST_RUNNING = "least_running"
+	SELECTION_WEIGHTED = "weighted"
+	SELECTION_STICKY = "sticky"
 )
 
 type ExecutionPolicyJson struct {
 	Hosts string `json:"select"`
 	HostList []string `json:"hosts"`
 	Roles []string `json:"roles"`
+	Selection string `json:"selection"`
+	Count int `json:"count"`
+	Percent int `json:"percent"`
 }
 
 type ExecutionPolicy struct {
 	Hosts string
 	HostList []string
 	Roles []string
+	Selection string
+	Count int
+	Percent int
 }
 
 func NewExecutionPolicyFromJson(json ExecutionPolicyJson) (ExecutionPolicy, error) {
-	return ExecutionPolicy {
+	policy := ExecutionPolicy {
 		Hosts: json.Hosts,
 		HostList: json.HostList,
 		Roles: json.Roles,
-	}, nil
+		Selection: json.Selection,
+		Count: json.Count,
+		Percent: json.Percent,
+	}
+
+	if len(policy.Selection) == 0 {
+		policy.Selection = SELECTION_RANDOM
+	}
+
+	return policy, nil
 }
 
 func (p ExecutionPolicy) IsValid() error {
-	if p.Hosts == POLICY_ALL || p.Hosts == POLICY_ANY {
+	if p.Hosts == POLICY_ALL || p.Hosts == POLICY_ANY || p.Hosts == POLICY_SOME {
 		if len(p.HostList) == 0 && len(p.Roles) == 0 {
 			return errors.New("Either 'HostList' or 'Roles' must have at least one entry")
 		}
 	} else {
-		return errors.New("'Hosts' must be 'all' or 'any'")
+		return errors.New("'Hosts' must be 'all', 'any' or 'some'")
+	}
+
+	if p.Hosts == POLICY_SOME {
+		if (p.Count == 0) == (p.Percent == 0) {
+			return errors.New("Exactly one of 'Count' or 'Percent' must be specified")
+		}
+
+		if p.Count < 0 {
+			return errors.New("'Count' must be positive")
+		}
+
+		if p.Percent < 0 || p.Percent > 100 {
+			return errors.New("'Percent' must be between 1 and 100")
+		}
+	} else if p.Count != 0 || p.Percent != 0 {
+		return errors.New("'Count' and 'Percent' can only be used with 'some'")
+	}
+
+	switch p.Selection {
+	case SELECTION_RANDOM, SELECTION_ROUND_ROBIN, SELECTION_LEAST_RUNNING, SELECTION_WEIGHTED, SELECTION_STICKY:
+	default:
+		return errors.New(fmt.Sprintf("'Selection' must be one of '%s', '%s', '%s', '%s' or '%s'", SELECTION_RANDOM, SELECTION_ROUND_ROBIN, SELECTION_LEAST_RUNNING, SELECTION_WEIGHTED, SELECTION_STICKY))
 	}
 
 	return nil
 }
+
+// The number of nodes that a job should run on, given the number of nodes
+// that match the policy. Percentages are rounded up, so that a job runs on at
+// least one node.
+func (p ExecutionPolicy) NodeCount(matching int) int {
+	switch p.Hosts {
+	case POLICY_ALL:
+		return matching
+	case POLICY_SOME:
+		if p.Count > 0 {
+			return p.Count
+		}
+		return (matching * p.Percent + 99) / 100
+	default:
+		return 1
+	}
+}
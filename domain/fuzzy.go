@@ -0,0 +1,209 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Fuzzy schedules leave the exact execution time up to distcrond. There are
+// two notations:
+//
+// - Cron expressions with "H" in place of a concrete value. "H" may also be
+//   restricted to a range ("H(0-29)") or be used as offset for a step ("H/15").
+//   Just like in Jenkins, expressions with five fields are interpreted with
+//   minute precision ("H H * * *" means "once a day"); the seconds are then
+//   chosen by distcrond, too.
+// - "@hourly~", "@daily~" and "@weekly~", optionally followed by a window in
+//   which the job should run, counted from the start of the period (for
+//   example, "@daily~4h" means "once a day, somewhen between 0:00 and 4:00").
+//
+// The concrete time is derived from a position within [0, 1), which is in
+// turn derived from the job name and the nodes the job runs on. This way, a
+// job is always run at the same time, even across restarts and when other jobs
+// are added or removed. The hash spreads jobs over the period uniformly at
+// random, so jobs on the same nodes may still happen to run close together.
+
+type fieldBounds struct {
+	min, max int
+}
+
+// Bounds for each field of a six-field cron expression. The day of month is
+// limited to 28, so that each month is covered.
+var fuzzyFieldBounds = []fieldBounds{
+	{0, 59}, // seconds
+	{0, 59}, // minutes
+	{0, 23}, // hours
+	{1, 28}, // day of month
+	{1, 12}, // month
+	{0, 6},  // day of week
+}
+
+// Field indices, sorted from most to least significant. The most significant
+// field determines the coarse position of a job within its period.
+var fuzzyFieldSignificance = []int{4, 3, 5, 2, 1, 0}
+
+var fuzzyPeriods = map[string]time.Duration{
+	"@hourly~": time.Hour,
+	"@daily~": 24 * time.Hour,
+	"@weekly~": 7 * 24 * time.Hour,
+}
+
+var fuzzyFieldPattern = regexp.MustCompile(`^H(?:\((\d+)-(\d+)\))?(?:/(\d+))?$`)
+
+func IsFuzzySchedule(spec string) bool {
+	if strings.HasPrefix(spec, "@") {
+		return strings.Contains(spec, "~")
+	}
+
+	for _, field := range strings.Fields(spec) {
+		if strings.HasPrefix(field, "H") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Derives a position within [0, 1) from a job name.
+func FuzzyPosition(name string) float64 {
+	hash := fnv.New32a()
+	hash.Write([]byte(name))
+	return float64(hash.Sum32()) / (1 << 32)
+}
+
+// Derives the position of a job from its name and the set of nodes it may run
+// on.
+func FuzzyJobPosition(name string, policy ExecutionPolicy) float64 {
+	roles := append([]string{}, policy.Roles...)
+	hosts := append([]string{}, policy.HostList...)
+
+	sort.Strings(roles)
+	sort.Strings(hosts)
+
+	return FuzzyPosition("roles=" + strings.Join(roles, ",") + ";hosts=" + strings.Join(hosts, ",") + "/" + name)
+}
+
+// Resolves a fuzzy schedule into a regular six-field cron expression. The
+// position must be within [0, 1).
+func ResolveFuzzySchedule(spec string, position float64) (string, error) {
+	if position < 0 || position >= 1 {
+		return "", errors.New(fmt.Sprintf("Position %f is not within [0, 1)", position))
+	}
+
+	if strings.HasPrefix(spec, "@") {
+		return resolveFuzzyPeriod(spec, position)
+	}
+
+	return resolveFuzzyFields(spec, position)
+}
+
+func resolveFuzzyPeriod(spec string, position float64) (string, error) {
+	tilde := strings.Index(spec, "~")
+	if tilde < 0 {
+		return "", errors.New(fmt.Sprintf("Not a fuzzy schedule: %s", spec))
+	}
+
+	period, ok := fuzzyPeriods[spec[:tilde + 1]]
+	if !ok {
+		return "", errors.New(fmt.Sprintf("Unknown fuzzy schedule %s (must be one of @hourly~, @daily~ or @weekly~)", spec))
+	}
+
+	window := period
+	if len(spec) > tilde + 1 {
+		var err error
+		if window, err = time.ParseDuration(spec[tilde + 1:]); err != nil {
+			return "", errors.New(fmt.Sprintf("Invalid window in fuzzy schedule %s: %s", spec, err))
+		}
+
+		if window <= 0 || window > period {
+			return "", errors.New(fmt.Sprintf("Window in fuzzy schedule %s must be positive and not exceed %s", spec, period))
+		}
+	}
+
+	offset := int(position * window.Seconds())
+	second, minute, hour, day := offset % 60, offset / 60 % 60, offset / 3600 % 24, offset / 86400
+
+	switch period {
+	case time.Hour:
+		return fmt.Sprintf("%d %d * * * *", second, minute), nil
+	case 24 * time.Hour:
+		return fmt.Sprintf("%d %d %d * * *", second, minute, hour), nil
+	default:
+		return fmt.Sprintf("%d %d %d * * %d", second, minute, hour, day), nil
+	}
+}
+
+func resolveFuzzyFields(spec string, position float64) (string, error) {
+	fields := strings.Fields(spec)
+
+	switch len(fields) {
+	case 5:
+		fields = append([]string{"H"}, fields...)
+	case 6:
+	default:
+		return "", errors.New(fmt.Sprintf("Fuzzy schedule %s must have five or six fields", spec))
+	}
+
+	// For each "H" field, the range of possible values and the format in
+	// which the chosen value is inserted into the expression.
+	ranges := make([]fieldBounds, len(fields))
+	formats := make([]string, len(fields))
+	total := 1.0
+
+	for i, field := range fields {
+		if !strings.HasPrefix(field, "H") {
+			continue
+		}
+
+		match := fuzzyFieldPattern.FindStringSubmatch(field)
+		if match == nil {
+			return "", errors.New(fmt.Sprintf("Invalid fuzzy field '%s' in schedule %s", field, spec))
+		}
+
+		bounds := fuzzyFieldBounds[i]
+		formats[i] = "%d"
+
+		if len(match[1]) > 0 {
+			low, _ := strconv.Atoi(match[1])
+			high, _ := strconv.Atoi(match[2])
+			if low > high || low < bounds.min || high > bounds.max {
+				return "", errors.New(fmt.Sprintf("Range in fuzzy field '%s' must be within %d-%d", field, bounds.min, bounds.max))
+			}
+			bounds = fieldBounds{low, high}
+		}
+
+		if len(match[3]) > 0 {
+			step, _ := strconv.Atoi(match[3])
+			if step < 1 || bounds.min + step - 1 > bounds.max {
+				return "", errors.New(fmt.Sprintf("Invalid step in fuzzy field '%s'", field))
+			}
+			formats[i] = fmt.Sprintf("%%d-%d/%d", bounds.max, step)
+			bounds = fieldBounds{bounds.min, bounds.min + step - 1}
+		}
+
+		ranges[i] = bounds
+		total *= float64(bounds.max - bounds.min + 1)
+	}
+
+	// Interpret the position as a mixed-radix number, with the most
+	// significant field determining the coarse placement.
+	value := int64(position * total)
+	for j := len(fuzzyFieldSignificance) - 1; j >= 0; j -- {
+		i := fuzzyFieldSignificance[j]
+		if len(formats[i]) == 0 {
+			continue
+		}
+
+		size := int64(ranges[i].max - ranges[i].min + 1)
+		fields[i] = fmt.Sprintf(formats[i], int64(ranges[i].min) + value % size)
+		value /= size
+	}
+
+	return strings.Join(fields, " "), nil
+}
@@ -0,0 +1,105 @@
+package domain
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"github.com/robfig/cron"
+)
+
+func TestIsFuzzyScheduleDetectsFuzzySchedules(t *testing.T) {
+	if !IsFuzzySchedule("H H * * *") || !IsFuzzySchedule("@daily~") || !IsFuzzySchedule("0 H(0-29) 3 * * *") {
+		t.Error("Fuzzy schedule not detected")
+	}
+
+	if IsFuzzySchedule("0 * * * * *") || IsFuzzySchedule("@daily") || IsFuzzySchedule("@every 1h") {
+		t.Error("Regular schedule detected as fuzzy")
+	}
+}
+
+func TestResolveFuzzyScheduleIsDeterministic(t *testing.T) {
+	position := FuzzyPosition("some-job")
+
+	first, err := ResolveFuzzySchedule("H H * * *", position)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, _ := ResolveFuzzySchedule("H H * * *", position)
+	if first != second {
+		t.Errorf("Resolved schedules differ: %s and %s", first, second)
+	}
+}
+
+func TestResolveFuzzyScheduleResolvesJenkinsStyleSchedule(t *testing.T) {
+	resolved, err := ResolveFuzzySchedule("H H * * *", 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fields := strings.Fields(resolved)
+	if len(fields) != 6 || fields[3] != "*" || fields[4] != "*" || fields[5] != "*" {
+		t.Errorf("Unexpected resolved schedule %s", resolved)
+	}
+
+	if hour, _ := strconv.Atoi(fields[2]); hour != 12 {
+		t.Errorf("Expected hour 12 for position 0.5, got %s", resolved)
+	}
+
+	if _, err := cron.Parse(resolved); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestResolveFuzzyScheduleRespectsRangesAndSteps(t *testing.T) {
+	for _, position := range []float64{0, 0.25, 0.5, 0.99} {
+		resolved, err := ResolveFuzzySchedule("0 H/15 H(2-4) * * *", position)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		fields := strings.Fields(resolved)
+		if !strings.HasSuffix(fields[1], "-59/15") {
+			t.Errorf("Step not preserved in %s", resolved)
+		}
+
+		if hour, _ := strconv.Atoi(fields[2]); hour < 2 || hour > 4 {
+			t.Errorf("Hour out of range in %s", resolved)
+		}
+
+		if _, err := cron.Parse(resolved); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestResolveFuzzyScheduleRespectsWindow(t *testing.T) {
+	for _, position := range []float64{0, 0.5, 0.999} {
+		resolved, err := ResolveFuzzySchedule("@daily~4h", position)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		fields := strings.Fields(resolved)
+		if hour, _ := strconv.Atoi(fields[2]); hour >= 4 {
+			t.Errorf("Hour outside of window in %s", resolved)
+		}
+	}
+
+	if _, err := ResolveFuzzySchedule("@hourly~2h", 0.5); err == nil {
+		t.Error("Window exceeding period not rejected")
+	}
+}
+
+func TestFuzzyJobPositionDependsOnNodeSetOnly(t *testing.T) {
+	first := FuzzyJobPosition("backup", ExecutionPolicy{Roles: []string{"db", "web"}})
+	second := FuzzyJobPosition("backup", ExecutionPolicy{Roles: []string{"web", "db"}, Selection: "round_robin"})
+
+	if first != second {
+		t.Errorf("Expected the same position regardless of the order of roles, got %f and %f", first, second)
+	}
+
+	if other := FuzzyJobPosition("backup", ExecutionPolicy{Roles: []string{"db"}}); other == first {
+		t.Error("Expected a different position for a different node set")
+	}
+}
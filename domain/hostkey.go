@@ -0,0 +1,53 @@
+package domain
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"golang.org/x/crypto/ssh"
+)
+
+// Returned when a node presents a different host key than the one that is
+// pinned or recorded for it. This might mean that somebody is intercepting the
+// connection, so no commands are run on the node until the problem is solved.
+type HostKeyMismatchError struct {
+	Host string
+	Fingerprint string
+	Expected []string
+}
+
+func (e *HostKeyMismatchError) Error() string {
+	return fmt.Sprintf("Host key of %s does not match (got %s, expected %s)", e.Host, e.Fingerprint, strings.Join(e.Expected, " or "))
+}
+
+// Computes a key's fingerprint in the format of OpenSSH ("SHA256:...").
+func FingerprintSHA256(key ssh.PublicKey) string {
+	hash := sha256.Sum256(key.Marshal())
+	return "SHA256:" + strings.TrimRight(base64.StdEncoding.EncodeToString(hash[:]), "=")
+}
+
+// Computes a key's legacy fingerprint ("MD5:aa:bb:...").
+func FingerprintMD5(key ssh.PublicKey) string {
+	hash := md5.Sum(key.Marshal())
+
+	parts := make([]string, len(hash))
+	for i, b := range hash {
+		parts[i] = fmt.Sprintf("%02x", b)
+	}
+
+	return "MD5:" + strings.Join(parts, ":")
+}
+
+// Checks if a key has the given fingerprint, which may be a SHA256 or an MD5
+// fingerprint (the "MD5:" prefix is optional).
+func FingerprintMatches(key ssh.PublicKey, fingerprint string) bool {
+	fingerprint = strings.TrimSpace(fingerprint)
+
+	if strings.HasPrefix(fingerprint, "SHA256:") {
+		return strings.TrimRight(fingerprint, "=") == FingerprintSHA256(key)
+	}
+
+	return strings.ToLower(strings.TrimPrefix(fingerprint, "MD5:")) == strings.TrimPrefix(FingerprintMD5(key), "MD5:")
+}
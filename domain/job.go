@@ -9,6 +9,10 @@ import (
 	"sync"
 )
 
+// Time to wait for a free slot on a node that is running as many jobs as it
+// may, before giving up
+const DEFAULT_SLOT_WAIT = 15 * time.Minute
+
 type JobValidationConfig interface {
 	AllowNoOwner() bool
 }
@@ -21,6 +25,22 @@ type JobJson struct {
 	ShellCommand string `json:"shell_command"`
 	Command []string `json:"command"`
 	Environment map[string]string `json:"environment"`
+	CombinedOutput bool `json:"combined_output"`
+	MaxOutputBytes int `json:"max_output_bytes"`
+	SpoolOutput bool `json:"spool_output"`
+	Timeout string `json:"timeout"`
+	SlotWait string `json:"slot_wait"`
+	Retries int `json:"retries"`
+	RetryBackoff string `json:"retry_backoff"`
+	RetryOn []string `json:"retry_on"`
+	ConcurrencyPolicy string `json:"concurrency_policy"`
+	ConcurrencyLimit int `json:"concurrency_limit"`
+	CatchUp string `json:"catchup"`
+	CatchUpLimit int `json:"catchup_limit"`
+	Timezone string `json:"timezone"`
+	DependsOn []string `json:"depends_on"`
+	Trigger string `json:"trigger"`
+	Rolling *RollingPolicyJson `json:"rolling"`
 }
 
 type Job struct {
@@ -31,11 +51,33 @@ type Job struct {
 	Policy ExecutionPolicy
 	Schedule cron.Schedule
 	ScheduleSpec string
+	ResolvedScheduleSpec string
+	Fuzzy bool
+	Location *time.Location
 	Command Command
 	LastExecution time.Time
+	LastDispatch time.Time
+	LastNode string
+	Paused bool
 	Environment map[string]string
+	CombinedOutput bool
+	MaxOutputBytes int
+	SpoolOutput bool
+	OutputDirectory string
+	Timeout time.Duration
+	SlotWait time.Duration
+	Retry RetryPolicy
+	Concurrency ConcurrencyPolicy
+	CatchUp CatchUpPolicy
+	Dependency Dependency
+	Rolling *RollingPolicy
+
+	// Names of the jobs that depend on this job. This is populated when
+	// all jobs are loaded.
+	Downstream []string
 
 	// Auxiliary properties
+	Definition JobJson
 	Logger *logging.Logger
 	Lock sync.RWMutex
 }
@@ -66,9 +108,71 @@ func NewJobFromJson(name string, json JobJson) (Job, error) {
 		return Job{}, pErr
 	}
 
-	schedule, sErr := cron.Parse(json.Schedule)
-	if sErr != nil {
-		return Job{}, sErr
+	fuzzy := IsFuzzySchedule(json.Schedule)
+	resolvedSchedule := json.Schedule
+	if fuzzy {
+		var fErr error
+		if resolvedSchedule, fErr = ResolveFuzzySchedule(json.Schedule, FuzzyJobPosition(name, policy)); fErr != nil {
+			return Job{}, fErr
+		}
+	}
+
+	location := time.Local
+	if len(json.Timezone) > 0 {
+		var lErr error
+		if location, lErr = time.LoadLocation(json.Timezone); lErr != nil {
+			return Job{}, errors.New(fmt.Sprintf("Invalid time zone '%s': %s", json.Timezone, lErr))
+		}
+	}
+
+	// Jobs that are only triggered by other jobs do not need a schedule
+	var schedule cron.Schedule
+	if len(json.Schedule) > 0 {
+		var sErr error
+		if schedule, sErr = ParseSchedule(resolvedSchedule, location); sErr != nil {
+			return Job{}, sErr
+		}
+	}
+
+	var timeout time.Duration
+	if len(json.Timeout) > 0 {
+		var tErr error
+		if timeout, tErr = time.ParseDuration(json.Timeout); tErr != nil {
+			return Job{}, errors.New(fmt.Sprintf("Invalid timeout '%s': %s", json.Timeout, tErr))
+		}
+	}
+
+	slotWait := DEFAULT_SLOT_WAIT
+	if len(json.SlotWait) > 0 {
+		var swErr error
+		if slotWait, swErr = time.ParseDuration(json.SlotWait); swErr != nil {
+			return Job{}, errors.New(fmt.Sprintf("Invalid slot wait '%s': %s", json.SlotWait, swErr))
+		}
+	}
+
+	retry, rErr := NewRetryPolicyFromJson(json)
+	if rErr != nil {
+		return Job{}, rErr
+	}
+
+	concurrency, cErr := NewConcurrencyPolicyFromJson(json)
+	if cErr != nil {
+		return Job{}, cErr
+	}
+
+	catchUp, cuErr := NewCatchUpPolicyFromJson(json)
+	if cuErr != nil {
+		return Job{}, cuErr
+	}
+
+	dependency, dErr := NewDependencyFromJson(json)
+	if dErr != nil {
+		return Job{}, dErr
+	}
+
+	rolling, roErr := NewRollingPolicyFromJson(json)
+	if roErr != nil {
+		return Job{}, roErr
 	}
 
 	logger, lErr := logging.GetLogger(name)
@@ -83,12 +187,36 @@ func NewJobFromJson(name string, json JobJson) (Job, error) {
 		Policy: policy,
 		Schedule: schedule,
 		ScheduleSpec: json.Schedule,
+		ResolvedScheduleSpec: resolvedSchedule,
+		Fuzzy: fuzzy,
+		Location: location,
 		Command: command,
 		Environment: json.Environment,
+		CombinedOutput: json.CombinedOutput,
+		MaxOutputBytes: json.MaxOutputBytes,
+		SpoolOutput: json.SpoolOutput,
+		Timeout: timeout,
+		SlotWait: slotWait,
+		Retry: retry,
+		Concurrency: concurrency,
+		CatchUp: catchUp,
+		Dependency: dependency,
+		Rolling: rolling,
+		Definition: json,
 		Logger: logger,
 	}, nil
 }
 
+// Applies the global output settings. The global limit only applies to jobs
+// without their own limit.
+func (j *Job) ApplyOutputDefaults(maxOutputBytes int, outputDirectory string) {
+	if j.MaxOutputBytes == 0 {
+		j.MaxOutputBytes = maxOutputBytes
+	}
+
+	j.OutputDirectory = outputDirectory
+}
+
 func (j Job) IsValid(config JobValidationConfig) error {
 	if len(j.Name) == 0 {
 		return errors.New("Job name must not be empty")
@@ -98,10 +226,56 @@ func (j Job) IsValid(config JobValidationConfig) error {
 		return errors.New("Job must have specified at least one owner")
 	}
 
+	if j.Schedule == nil && len(j.Dependency.Upstream) == 0 {
+		return errors.New("Job must have a schedule or depend on other jobs")
+	}
+
 	if err := j.Policy.IsValid(); err != nil {
 		return errors.New(fmt.Sprintf("Invalid execution policy: %s", err))
 	}
 
+	if j.Timeout < 0 {
+		return errors.New("Timeout must not be negative")
+	}
+
+	if j.SlotWait < 0 {
+		return errors.New("Slot wait must not be negative")
+	}
+
+	if j.MaxOutputBytes < 0 {
+		return errors.New("Max output bytes must not be negative")
+	}
+
+	if j.SpoolOutput && len(j.OutputDirectory) == 0 {
+		return errors.New("Output can only be spooled when an output directory is configured")
+	}
+
+	if err := j.Retry.IsValid(); err != nil {
+		return errors.New(fmt.Sprintf("Invalid retry policy: %s", err))
+	}
+
+	if err := j.Concurrency.IsValid(); err != nil {
+		return errors.New(fmt.Sprintf("Invalid concurrency policy: %s", err))
+	}
+
+	if err := j.CatchUp.IsValid(); err != nil {
+		return errors.New(fmt.Sprintf("Invalid catch-up policy: %s", err))
+	}
+
+	if err := j.Dependency.IsValid(); err != nil {
+		return errors.New(fmt.Sprintf("Invalid dependency: %s", err))
+	}
+
+	if j.Rolling != nil {
+		if j.Policy.Hosts != POLICY_ALL {
+			return errors.New("Rolling execution is only possible with the 'all' policy")
+		}
+
+		if err := j.Rolling.IsValid(); err != nil {
+			return errors.New(fmt.Sprintf("Invalid rolling policy: %s", err))
+		}
+	}
+
 	for i, owner := range(j.Owners) {
 		if err := owner.IsValid(); err != nil {
 			return errors.New(fmt.Sprintf("Invalid owner %d: %s", i, err))
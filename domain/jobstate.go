@@ -0,0 +1,98 @@
+package domain
+
+import "time"
+
+// Runtime state of a job that needs to survive a restart.
+type JobStateJson struct {
+	Job string `json:"job"`
+	LastExecution time.Time `json:"last_execution"`
+	LastDispatch time.Time `json:"last_dispatch"`
+	LastNode string `json:"last_node,omitempty"`
+	Paused bool `json:"paused"`
+}
+
+func (j *Job) State() JobStateJson {
+	j.Lock.RLock()
+	defer j.Lock.RUnlock()
+
+	return JobStateJson{
+		Job: j.Name,
+		LastExecution: j.LastExecution,
+		LastDispatch: j.LastDispatch,
+		LastNode: j.LastNode,
+		Paused: j.Paused,
+	}
+}
+
+func (j *Job) RestoreState(state JobStateJson) {
+	j.Lock.Lock()
+	defer j.Lock.Unlock()
+
+	j.LastExecution = state.LastExecution
+	j.LastDispatch = state.LastDispatch
+	j.LastNode = state.LastNode
+	j.Paused = state.Paused
+}
+
+func (j *Job) IsPaused() bool {
+	j.Lock.RLock()
+	defer j.Lock.RUnlock()
+
+	return j.Paused
+}
+
+func (j *Job) SetPaused(paused bool) {
+	j.Lock.Lock()
+	defer j.Lock.Unlock()
+
+	j.Paused = paused
+}
+
+// Records that the run scheduled for "t" was started.
+func (j *Job) SetLastDispatch(t time.Time) {
+	j.Lock.Lock()
+	defer j.Lock.Unlock()
+
+	if t.After(j.LastDispatch) {
+		j.LastDispatch = t
+	}
+}
+
+// Records the node that the job was last run on.
+func (j *Job) SetLastNode(name string) {
+	j.Lock.Lock()
+	defer j.Lock.Unlock()
+
+	j.LastNode = name
+}
+
+func (j *Job) GetLastNode() string {
+	j.Lock.RLock()
+	defer j.Lock.RUnlock()
+
+	return j.LastNode
+}
+
+// The point in time up to which all scheduled runs were either started or
+// completed. Runs that were started, but not completed (for example, because
+// another instance was running them) must not be caught up on.
+func (s JobStateJson) HandledUntil() time.Time {
+	if s.LastDispatch.After(s.LastExecution) {
+		return s.LastDispatch
+	}
+	return s.LastExecution
+}
+
+// Keeps the later timestamps of both states, so that saving an outdated state
+// does not move them backwards.
+func (s JobStateJson) MergeTimes(previous JobStateJson) JobStateJson {
+	if previous.LastExecution.After(s.LastExecution) {
+		s.LastExecution = previous.LastExecution
+	}
+
+	if previous.LastDispatch.After(s.LastDispatch) {
+		s.LastDispatch = previous.LastDispatch
+	}
+
+	return s
+}
@@ -0,0 +1,117 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A simple JSONPath expression that selects a single value from a decoded JSON
+// document. Only the child operators are supported, in dot notation
+// ("$.result.status") and bracket notation ("$['result']['items'][0]"); array
+// indices may be negative to count from the end.
+type JsonPath struct {
+	expression string
+	steps []jsonPathStep
+}
+
+type jsonPathStep struct {
+	key string
+	index int
+	isIndex bool
+}
+
+func ParseJsonPath(expression string) (JsonPath, error) {
+	path := JsonPath{expression: expression}
+
+	if !strings.HasPrefix(expression, "$") {
+		return path, errors.New(fmt.Sprintf("JSONPath '%s' must start with '$'", expression))
+	}
+
+	rest := expression[1:]
+	for len(rest) > 0 {
+		switch rest[0] {
+		case '.':
+			end := strings.IndexAny(rest[1:], ".[")
+			if end < 0 {
+				end = len(rest) - 1
+			}
+
+			key := rest[1:end + 1]
+			if len(key) == 0 {
+				return path, errors.New(fmt.Sprintf("Empty key in JSONPath '%s'", expression))
+			}
+
+			path.steps = append(path.steps, jsonPathStep{key: key})
+			rest = rest[end + 1:]
+
+		case '[':
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return path, errors.New(fmt.Sprintf("Unterminated bracket in JSONPath '%s'", expression))
+			}
+
+			inner := rest[1:end]
+			if len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner) - 1] == inner[0] {
+				path.steps = append(path.steps, jsonPathStep{key: inner[1:len(inner) - 1]})
+			} else if index, err := strconv.Atoi(inner); err == nil {
+				path.steps = append(path.steps, jsonPathStep{index: index, isIndex: true})
+			} else {
+				return path, errors.New(fmt.Sprintf("Invalid subscript [%s] in JSONPath '%s'", inner, expression))
+			}
+
+			rest = rest[end + 1:]
+
+		default:
+			return path, errors.New(fmt.Sprintf("Unexpected '%c' in JSONPath '%s'", rest[0], expression))
+		}
+	}
+
+	return path, nil
+}
+
+// Selects the value from a document decoded with encoding/json. The second
+// return value is false if the value does not exist.
+func (p JsonPath) Lookup(document interface{}) (interface{}, bool) {
+	current := document
+
+	for _, step := range p.steps {
+		switch value := current.(type) {
+		case map[string]interface{}:
+			if step.isIndex {
+				return nil, false
+			}
+
+			child, ok := value[step.key]
+			if !ok {
+				return nil, false
+			}
+			current = child
+
+		case []interface{}:
+			if !step.isIndex {
+				return nil, false
+			}
+
+			index := step.index
+			if index < 0 {
+				index += len(value)
+			}
+
+			if index < 0 || index >= len(value) {
+				return nil, false
+			}
+			current = value[index]
+
+		default:
+			return nil, false
+		}
+	}
+
+	return current, true
+}
+
+func (p JsonPath) String() string {
+	return p.expression
+}
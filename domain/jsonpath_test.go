@@ -0,0 +1,37 @@
+package domain
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestJsonPathSelectsValues(t *testing.T) {
+	var document interface{}
+	json.Unmarshal([]byte(`{"result": {"items": [{"name": "a"}, {"name": "b"}], "ok": true}}`), &document)
+
+	cases := map[string]interface{}{
+		"$.result.ok": true,
+		"$.result.items[1].name": "b",
+		"$['result']['items'][-2]['name']": "a",
+	}
+
+	for expression, expected := range cases {
+		path, err := ParseJsonPath(expression)
+		if err != nil {
+			t.Fatalf("Could not parse %s: %s", expression, err)
+		}
+
+		if value, ok := path.Lookup(document); !ok || value != expected {
+			t.Errorf("Expected %s to select %v, got %v", expression, expected, value)
+		}
+	}
+
+	path, _ := ParseJsonPath("$.result.missing")
+	if _, ok := path.Lookup(document); ok {
+		t.Error("Expected missing key not to be found")
+	}
+
+	if _, err := ParseJsonPath("result.ok"); err == nil {
+		t.Error("Expected path without '$' to be rejected")
+	}
+}
@@ -0,0 +1,254 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+)
+
+const (
+	maintenanceDateFormat = "2006-01-02"
+	maintenanceTimeFormat = "15:04"
+	maintenanceDateTimeFormat = "2006-01-02 15:04"
+)
+
+var weekdays = map[string]time.Weekday{
+	"sunday": time.Sunday,
+	"monday": time.Monday,
+	"tuesday": time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday": time.Thursday,
+	"friday": time.Friday,
+	"saturday": time.Saturday,
+}
+
+// A maintenance window is a period of time in which jobs are not run. It is
+// either a single period ("start" and "end"), or recurs on certain weekdays
+// or dates, optionally restricted to a time of day ("from" and "until"; if
+// "until" is before "from", the window extends into the next day).
+//
+// Windows without jobs, nodes and roles apply to all jobs. Windows with nodes
+// or roles only exclude these nodes from running jobs; windows with jobs only
+// apply to these jobs (job names may contain wildcards like "billing-*").
+type MaintenanceWindowJson struct {
+	Description string `json:"description"`
+	Jobs []string `json:"jobs,omitempty"`
+	Nodes []string `json:"nodes,omitempty"`
+	Roles []string `json:"roles,omitempty"`
+	Timezone string `json:"timezone,omitempty"`
+	Start string `json:"start,omitempty"`
+	End string `json:"end,omitempty"`
+	Weekdays []string `json:"weekdays,omitempty"`
+	Dates []string `json:"dates,omitempty"`
+	From string `json:"from,omitempty"`
+	Until string `json:"until,omitempty"`
+}
+
+type MaintenanceWindow struct {
+	Id string
+	Description string
+	Jobs []string
+	Nodes []string
+	Roles []string
+	Location *time.Location
+	Start time.Time
+	End time.Time
+	Weekdays []time.Weekday
+	Dates []string
+	From time.Duration
+	Until time.Duration
+	Daily bool
+
+	Definition MaintenanceWindowJson
+}
+
+func NewMaintenanceWindowFromJson(id string, json MaintenanceWindowJson) (*MaintenanceWindow, error) {
+	window := &MaintenanceWindow{
+		Id: id,
+		Description: json.Description,
+		Jobs: json.Jobs,
+		Nodes: json.Nodes,
+		Roles: json.Roles,
+		Location: time.Local,
+		Dates: json.Dates,
+		Definition: json,
+	}
+
+	if len(json.Timezone) > 0 {
+		var err error
+		if window.Location, err = time.LoadLocation(json.Timezone); err != nil {
+			return nil, errors.New(fmt.Sprintf("Invalid time zone '%s': %s", json.Timezone, err))
+		}
+	}
+
+	parseDateTime := func(value string) (time.Time, error) {
+		if t, err := time.Parse(time.RFC3339, value); err == nil {
+			return t, nil
+		}
+		return time.ParseInLocation(maintenanceDateTimeFormat, value, window.Location)
+	}
+
+	parseTimeOfDay := func(value string) (time.Duration, error) {
+		t, err := time.Parse(maintenanceTimeFormat, value)
+		if err != nil {
+			return 0, errors.New(fmt.Sprintf("Invalid time of day '%s' (must be HH:MM)", value))
+		}
+		return time.Duration(t.Hour()) * time.Hour + time.Duration(t.Minute()) * time.Minute, nil
+	}
+
+	var err error
+	if len(json.Start) > 0 || len(json.End) > 0 {
+		if window.Start, err = parseDateTime(json.Start); err != nil {
+			return nil, errors.New(fmt.Sprintf("Invalid start '%s' (must be 'YYYY-MM-DD HH:MM' or RFC 3339)", json.Start))
+		}
+		if window.End, err = parseDateTime(json.End); err != nil {
+			return nil, errors.New(fmt.Sprintf("Invalid end '%s' (must be 'YYYY-MM-DD HH:MM' or RFC 3339)", json.End))
+		}
+	}
+
+	for _, name := range json.Weekdays {
+		weekday, ok := weekdays[strings.ToLower(name)]
+		if !ok {
+			return nil, errors.New(fmt.Sprintf("Unknown weekday '%s'", name))
+		}
+		window.Weekdays = append(window.Weekdays, weekday)
+	}
+
+	for _, date := range json.Dates {
+		if _, err := time.Parse(maintenanceDateFormat, date); err != nil {
+			return nil, errors.New(fmt.Sprintf("Invalid date '%s' (must be YYYY-MM-DD)", date))
+		}
+	}
+
+	if len(json.From) > 0 || len(json.Until) > 0 {
+		window.Daily = true
+		if window.From, err = parseTimeOfDay(json.From); err != nil {
+			return nil, err
+		}
+		if window.Until, err = parseTimeOfDay(json.Until); err != nil {
+			return nil, err
+		}
+	}
+
+	return window, nil
+}
+
+func (w *MaintenanceWindow) IsValid() error {
+	if len(w.Id) == 0 {
+		return errors.New("Id is empty")
+	}
+
+	oneTime := !w.Start.IsZero()
+	recurring := len(w.Weekdays) > 0 || len(w.Dates) > 0 || w.Daily
+
+	if oneTime && recurring {
+		return errors.New("'Start' and 'End' cannot be combined with 'Weekdays', 'Dates', 'From' or 'Until'")
+	}
+
+	if !oneTime && !recurring {
+		return errors.New("Either 'Start' and 'End', or at least one of 'Weekdays', 'Dates', 'From' or 'Until' must be specified")
+	}
+
+	if oneTime && !w.End.After(w.Start) {
+		return errors.New("'End' must be after 'Start'")
+	}
+
+	if w.Daily && w.From == w.Until {
+		return errors.New("'From' and 'Until' must not be equal")
+	}
+
+	for _, pattern := range w.Jobs {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return errors.New(fmt.Sprintf("Invalid job pattern '%s'", pattern))
+		}
+	}
+
+	return nil
+}
+
+// Checks if jobs that this window applies to must not be run at all (as
+// opposed to windows that only exclude certain nodes).
+func (w *MaintenanceWindow) IsJobLevel() bool {
+	return len(w.Nodes) == 0 && len(w.Roles) == 0
+}
+
+func (w *MaintenanceWindow) AppliesToJob(job *Job) bool {
+	if len(w.Jobs) == 0 {
+		return true
+	}
+
+	for _, pattern := range w.Jobs {
+		if matched, _ := path.Match(pattern, job.Name); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (w *MaintenanceWindow) AppliesToNode(node *Node) bool {
+	for _, name := range w.Nodes {
+		if name == node.Name {
+			return true
+		}
+	}
+
+	for _, role := range w.Roles {
+		for _, nodeRole := range node.Roles {
+			if role == nodeRole {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Checks if a one-time window is over. Recurring windows never expire.
+func (w *MaintenanceWindow) IsExpired(t time.Time) bool {
+	return !w.End.IsZero() && !t.Before(w.End)
+}
+
+func (w *MaintenanceWindow) IsActive(t time.Time) bool {
+	if !w.Start.IsZero() {
+		return !t.Before(w.Start) && t.Before(w.End)
+	}
+
+	t = t.In(w.Location)
+	if !w.Daily {
+		return w.matchesDay(t)
+	}
+
+	// Use the wall clock time, so that the window is not shifted by daylight
+	// saving time transitions
+	offset := time.Duration(t.Hour()) * time.Hour + time.Duration(t.Minute()) * time.Minute + time.Duration(t.Second()) * time.Second
+
+	if w.From < w.Until {
+		return w.matchesDay(t) && offset >= w.From && offset < w.Until
+	}
+
+	return (w.matchesDay(t) && offset >= w.From) || (w.matchesDay(t.AddDate(0, 0, -1)) && offset < w.Until)
+}
+
+func (w *MaintenanceWindow) matchesDay(t time.Time) bool {
+	if len(w.Weekdays) == 0 && len(w.Dates) == 0 {
+		return true
+	}
+
+	for _, weekday := range w.Weekdays {
+		if t.Weekday() == weekday {
+			return true
+		}
+	}
+
+	date := t.Format(maintenanceDateFormat)
+	for _, d := range w.Dates {
+		if d == date {
+			return true
+		}
+	}
+
+	return false
+}
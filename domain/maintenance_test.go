@@ -0,0 +1,47 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMaintenanceWindowIsActiveOnWeekdaysAcrossMidnight(t *testing.T) {
+	window, err := NewMaintenanceWindowFromJson("w", MaintenanceWindowJson{
+		Timezone: "UTC",
+		Weekdays: []string{"saturday"},
+		From: "22:00",
+		Until: "02:00",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[time.Time]bool{
+		time.Date(2026, 10, 17, 21, 59, 0, 0, time.UTC): false,
+		time.Date(2026, 10, 17, 22, 0, 0, 0, time.UTC): true,
+		time.Date(2026, 10, 18, 1, 59, 0, 0, time.UTC): true,
+		time.Date(2026, 10, 18, 2, 0, 0, 0, time.UTC): false,
+		time.Date(2026, 10, 18, 23, 0, 0, 0, time.UTC): false,
+	}
+
+	for at, expected := range cases {
+		if window.IsActive(at) != expected {
+			t.Errorf("Expected window to be active at %s: %t", at, expected)
+		}
+	}
+}
+
+func TestMaintenanceWindowIsActiveOnDates(t *testing.T) {
+	window, _ := NewMaintenanceWindowFromJson("w", MaintenanceWindowJson{
+		Timezone: "UTC",
+		Dates: []string{"2026-12-25"},
+	})
+
+	if !window.IsActive(time.Date(2026, 12, 25, 12, 0, 0, 0, time.UTC)) {
+		t.Error("Expected window to be active on listed date")
+	}
+
+	if window.IsActive(time.Date(2026, 12, 26, 0, 0, 0, 0, time.UTC)) {
+		t.Error("Expected window to be inactive on other dates")
+	}
+}
@@ -3,12 +3,22 @@ package domain
 import (
 	"errors"
 	"fmt"
+	"net"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 const (
 	CONN_LOCAL = "local"
 	CONN_SSH = "ssh"
+	CONN_HTTP = "http"
+)
+
+const (
+	DEFAULT_SSH_MAX_SESSIONS = 10
+	DEFAULT_SSH_KEEPALIVE = 30 * time.Second
 )
 
 const (
@@ -23,14 +33,129 @@ type ConnectionOptions struct {
 	SshHost string `json:"ssh_host"`
 	SshUser string `json:"ssh_user"`
 	SshKeyFile string `json:"ssh_private_key_file"`
+	SshKeyPassphraseFile string `json:"ssh_private_key_passphrase_file"`
+	SshKeyPassphraseEnv string `json:"ssh_private_key_passphrase_env"`
+	SshCertificateFile string `json:"ssh_certificate_file"`
+	SshAgent bool `json:"ssh_agent"`
+	SshPasswordFile string `json:"ssh_password_file"`
+	SshPasswordEnv string `json:"ssh_password_env"`
+	SshHostKey string `json:"ssh_host_key"`
+	SshMaxSessions int `json:"ssh_max_sessions"`
+	SshKeepAlive string `json:"ssh_keepalive"`
+	SshJumpHosts []ConnectionOptions `json:"ssh_jump_hosts"`
+
+	HttpMethod string `json:"http_method"`
+	HttpUrl string `json:"http_url"`
+	HttpHeaders map[string]string `json:"http_headers"`
+	HttpBody string `json:"http_body"`
+	HttpSuccessStatus []int `json:"http_success_status"`
+	HttpSuccessPath string `json:"http_success_path"`
+	HttpSuccessValue *string `json:"http_success_value"`
+	HttpHealthUrl string `json:"http_health_url"`
 }
 
-func (o ConnectionOptions) SetDefaults(forType string) {
-	if forType == CONN_SSH {
+// Fills in the options that are not set, from the OpenSSH client
+// configuration (if not nil) first and then with the defaults. Hosts without a
+// port are given the default port, and "~" is expanded in file names.
+func (o *ConnectionOptions) SetDefaults(forType ConnectionType, sshConfig *SshClientConfig) {
+	switch forType {
+	case CONN_SSH:
+		o.setSshDefaults(sshConfig, true)
+
+	case CONN_HTTP:
+		if len(o.HttpMethod) == 0 && len(o.HttpBody) > 0 {
+			o.HttpMethod = "POST"
+		} else if len(o.HttpMethod) == 0 {
+			o.HttpMethod = "GET"
+		}
+	}
+}
+
+func (o *ConnectionOptions) setSshDefaults(sshConfig *SshClientConfig, proxyJump bool) {
+	if sshConfig != nil {
+		o.applySshConfig(sshConfig, proxyJump)
+	}
+
+	if len(o.SshHost) == 0 {
 		o.SshHost = "localhost"
+	}
+
+	if len(o.SshUser) == 0 {
 		o.SshUser = "root"
+	}
+
+	if len(o.SshKeyFile) == 0 && !o.SshAgent && !o.HasSshPassword() {
 		o.SshKeyFile = "~/.ssh/id_rsa"
 	}
+
+	host, port := splitSshHost(o.SshHost)
+	if len(port) == 0 {
+		port = "22"
+	}
+	o.SshHost = net.JoinHostPort(host, port)
+
+	o.SshKeyFile = ExpandHome(o.SshKeyFile)
+	o.SshKeyPassphraseFile = ExpandHome(o.SshKeyPassphraseFile)
+	o.SshCertificateFile = ExpandHome(o.SshCertificateFile)
+	o.SshPasswordFile = ExpandHome(o.SshPasswordFile)
+
+	// The jump hosts are shared with the node's definition
+	jumpHosts := make([]ConnectionOptions, len(o.SshJumpHosts))
+	copy(jumpHosts, o.SshJumpHosts)
+	for i := range jumpHosts {
+		jumpHosts[i].setSshDefaults(sshConfig, false)
+	}
+	o.SshJumpHosts = jumpHosts
+}
+
+// Resolves the host as an alias from the OpenSSH client configuration. Options
+// that are set explicitly take precedence.
+func (o *ConnectionOptions) applySshConfig(sshConfig *SshClientConfig, proxyJump bool) {
+	host, port := splitSshHost(o.SshHost)
+	if len(host) == 0 {
+		return
+	}
+
+	config := sshConfig.Lookup(host)
+
+	if len(config.HostName) > 0 {
+		host = config.HostName
+	}
+
+	if len(port) == 0 {
+		port = config.Port
+	}
+
+	o.SshHost = host
+	if len(port) > 0 {
+		o.SshHost = net.JoinHostPort(host, port)
+	}
+
+	if len(o.SshUser) == 0 {
+		o.SshUser = config.User
+	}
+
+	if len(o.SshKeyFile) == 0 && !o.SshAgent && !o.HasSshPassword() {
+		o.SshKeyFile = config.IdentityFile
+	}
+
+	if proxyJump && len(o.SshJumpHosts) == 0 && len(config.ProxyJump) > 0 && config.ProxyJump != "none" {
+		for _, hop := range strings.Split(config.ProxyJump, ",") {
+			jump := ConnectionOptions{SshHost: strings.TrimSpace(hop)}
+			if at := strings.LastIndex(jump.SshHost, "@"); at >= 0 {
+				jump.SshUser, jump.SshHost = jump.SshHost[:at], jump.SshHost[at + 1:]
+			}
+			o.SshJumpHosts = append(o.SshJumpHosts, jump)
+		}
+	}
+}
+
+// Splits an address into host and port; the port is empty if there is none.
+func splitSshHost(address string) (string, string) {
+	if host, port, err := net.SplitHostPort(address); err == nil {
+		return host, port
+	}
+	return strings.Trim(address, "[]"), ""
 }
 
 func (o ConnectionOptions) IsValid(forType ConnectionType) error {
@@ -44,8 +169,69 @@ func (o ConnectionOptions) IsValid(forType ConnectionType) error {
 			return errors.New("SSH user is empty")
 		}
 
+		if len(o.SshKeyFile) == 0 && !o.SshAgent && !o.HasSshPassword() {
+			return errors.New("No SSH authentication method configured (need a private key, the agent or a password)")
+		}
+
 		if len(o.SshKeyFile) == 0 {
-			return errors.New("SSH key is empty")
+			if len(o.SshKeyPassphraseFile) > 0 || len(o.SshKeyPassphraseEnv) > 0 {
+				return errors.New("SSH key passphrase given without a private key")
+			}
+
+			if len(o.SshCertificateFile) > 0 {
+				return errors.New("SSH certificate given without a private key")
+			}
+		}
+
+		if len(o.SshKeyPassphraseFile) > 0 && len(o.SshKeyPassphraseEnv) > 0 {
+			return errors.New("SSH key passphrase must be read either from a file or from an environment variable")
+		}
+
+		if len(o.SshPasswordFile) > 0 && len(o.SshPasswordEnv) > 0 {
+			return errors.New("SSH password must be read either from a file or from an environment variable")
+		}
+
+		if o.SshMaxSessions < 0 {
+			return errors.New("SSH max sessions must not be negative")
+		}
+
+		if _, err := o.SshKeepAliveInterval(); err != nil {
+			return err
+		}
+
+		for i, jump := range o.SshJumpHosts {
+			if len(jump.SshJumpHosts) > 0 {
+				return errors.New(fmt.Sprintf("Jump host %d must not have jump hosts itself (list all hops instead)", i + 1))
+			}
+
+			if err := jump.IsValid(CONN_SSH); err != nil {
+				return errors.New(fmt.Sprintf("Invalid jump host %d: %s", i + 1, err))
+			}
+		}
+
+	case forType == CONN_HTTP:
+		if len(o.HttpUrl) == 0 {
+			return errors.New("HTTP URL is empty")
+		}
+
+		for _, template := range []string{o.HttpUrl, o.HttpBody} {
+			if _, err := ParseRequestTemplate(template); err != nil {
+				return errors.New(fmt.Sprintf("Invalid HTTP request template: %s", err))
+			}
+		}
+
+		for _, status := range o.HttpSuccessStatus {
+			if status < 100 || status > 599 {
+				return errors.New(fmt.Sprintf("Invalid HTTP success status %d", status))
+			}
+		}
+
+		if len(o.HttpSuccessPath) > 0 {
+			if _, err := ParseJsonPath(o.HttpSuccessPath); err != nil {
+				return err
+			}
+		} else if o.HttpSuccessValue != nil {
+			return errors.New("HTTP success value given without a JSONPath")
 		}
 
 	case forType == CONN_LOCAL:
@@ -55,6 +241,52 @@ func (o ConnectionOptions) IsValid(forType ConnectionType) error {
 	return nil
 }
 
+// Checks if a response status indicates success. Without explicit success
+// statuses, all 2xx statuses do.
+func (o ConnectionOptions) IsHttpSuccessStatus(status int) bool {
+	if len(o.HttpSuccessStatus) == 0 {
+		return status >= 200 && status < 300
+	}
+
+	for _, s := range o.HttpSuccessStatus {
+		if s == status {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (o ConnectionOptions) HasSshPassword() bool {
+	return len(o.SshPasswordFile) > 0 || len(o.SshPasswordEnv) > 0
+}
+
+// The maximum number of sessions per SSH connection.
+func (o ConnectionOptions) SshSessionLimit() int {
+	if o.SshMaxSessions == 0 {
+		return DEFAULT_SSH_MAX_SESSIONS
+	}
+	return o.SshMaxSessions
+}
+
+// The interval in which SSH keepalives are sent ("0" disables keepalives).
+func (o ConnectionOptions) SshKeepAliveInterval() (time.Duration, error) {
+	if len(o.SshKeepAlive) == 0 {
+		return DEFAULT_SSH_KEEPALIVE, nil
+	}
+
+	interval, err := time.ParseDuration(o.SshKeepAlive)
+	if err != nil {
+		return 0, errors.New(fmt.Sprintf("Invalid SSH keepalive interval '%s': %s", o.SshKeepAlive, err))
+	}
+
+	if interval < 0 {
+		return 0, errors.New("SSH keepalive interval must not be negative")
+	}
+
+	return interval, nil
+}
+
 type ExecutionStrategy interface {
 	HealthCheck() error
 	ExecuteCommand(job *Job, report *RunReportItem) error
@@ -65,6 +297,8 @@ type NodeJson struct {
 	Roles []string `json:"roles"`
 	ConnectionType string `json:"connection_type"`
 	ConnectionOptions ConnectionOptions `json:"connection_options"`
+	MaxConcurrentJobs int `json:"max_concurrent_jobs"`
+	Weight int `json:"weight"`
 }
 
 type Node struct {
@@ -73,8 +307,12 @@ type Node struct {
 	ConnectionType    ConnectionType
 	ConnectionOptions ConnectionOptions
 	Status            NodeStatus
+	DownError         error
 	RunningJobs       int32
+	MaxConcurrentJobs int
+	Weight            int
 
+	Definition        NodeJson
 	ExecutionStrategy ExecutionStrategy
 	Lock              sync.RWMutex
 }
@@ -85,13 +323,20 @@ func NewNodeFromJson(name string, json NodeJson) (Node, error) {
 	node.Roles = json.Roles
 	node.ConnectionType = ConnectionType(json.ConnectionType)
 	node.ConnectionOptions = json.ConnectionOptions
+	node.MaxConcurrentJobs = json.MaxConcurrentJobs
+	node.Weight = json.Weight
+
+	if node.Weight == 0 {
+		node.Weight = 1
+	}
+	node.Definition = json
 
 	return node, nil
 }
 
 func (n Node) IsValid() error {
-	if n.ConnectionType != CONN_LOCAL && n.ConnectionType != CONN_SSH {
-		return errors.New("Invalid connection type (must be either " + CONN_LOCAL + " or " + CONN_SSH + ").")
+	if n.ConnectionType != CONN_LOCAL && n.ConnectionType != CONN_SSH && n.ConnectionType != CONN_HTTP {
+		return errors.New("Invalid connection type (must be one of " + CONN_LOCAL + ", " + CONN_SSH + " or " + CONN_HTTP + ").")
 	}
 
 	if len(n.Name) == 0 {
@@ -102,5 +347,46 @@ func (n Node) IsValid() error {
 		return errors.New(fmt.Sprintf("Invalid connection options: %s", err))
 	}
 
+	if n.MaxConcurrentJobs < 0 {
+		return errors.New("Max concurrent jobs must not be negative")
+	}
+
+	if n.Weight < 0 {
+		return errors.New("Weight must not be negative")
+	}
+
 	return nil
 }
+
+// Limits the number of concurrent jobs according to the node's roles, unless
+// the node has its own limit. The lowest limit of all roles applies.
+func (n *Node) ApplyRoleLimits(limits map[string]int) {
+	if n.MaxConcurrentJobs > 0 {
+		return
+	}
+
+	for _, role := range n.Roles {
+		if limit, ok := limits[role]; ok && (n.MaxConcurrentJobs == 0 || limit < n.MaxConcurrentJobs) {
+			n.MaxConcurrentJobs = limit
+		}
+	}
+}
+
+// Takes a slot for running a job on the node. Returns false when the node is
+// already running as many jobs as it may.
+func (n *Node) TryAcquireSlot() bool {
+	for {
+		running := atomic.LoadInt32(&n.RunningJobs)
+		if n.MaxConcurrentJobs > 0 && running >= int32(n.MaxConcurrentJobs) {
+			return false
+		}
+
+		if atomic.CompareAndSwapInt32(&n.RunningJobs, running, running + 1) {
+			return true
+		}
+	}
+}
+
+func (n *Node) ReleaseSlot() {
+	atomic.AddInt32(&n.RunningJobs, -1)
+}
@@ -0,0 +1,150 @@
+package domain
+
+import (
+	"sync"
+	"time"
+)
+
+// The number of bytes kept by output streams that are not given a limit
+const DefaultOutputStreamLimit = 1 << 20
+
+// A chunk of output, as written by a command.
+type OutputChunk struct {
+	Time time.Time
+	Node string
+	Stream string
+	Data []byte
+}
+
+// Collects the output of all commands of a run while the run is active, so
+// that it can be followed live. Readers that start late still receive the
+// output that was written before, up to the stream's limit; only the most
+// recent output is kept. Once the stream is closed and all readers are done,
+// the output is released.
+type OutputStream struct {
+	chunks []OutputChunk
+	dropped int
+	size int
+	limit int
+	closed bool
+	readers int
+	released bool
+	changed chan struct{}
+	lock sync.RWMutex
+}
+
+func NewOutputStream() *OutputStream {
+	return &OutputStream{
+		limit: DefaultOutputStreamLimit,
+		changed: make(chan struct{}),
+	}
+}
+
+// Limits the number of bytes that are kept (DefaultOutputStreamLimit if the
+// limit is 0).
+func (o *OutputStream) SetLimit(limit int) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	if limit <= 0 {
+		limit = DefaultOutputStreamLimit
+	}
+	o.limit = limit
+}
+
+func (o *OutputStream) Write(node string, stream string, p []byte) {
+	data := make([]byte, len(p))
+	copy(data, p)
+
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	if o.closed {
+		return
+	}
+
+	o.chunks = append(o.chunks, OutputChunk{time.Now(), node, stream, data})
+	o.size += len(data)
+
+	for o.limit > 0 && o.size > o.limit && len(o.chunks) > 1 {
+		o.size -= len(o.chunks[0].Data)
+		o.chunks[0] = OutputChunk{}
+		o.chunks = o.chunks[1:]
+		o.dropped ++
+	}
+
+	o.notify()
+}
+
+// Marks the end of the output. Closing a stream twice has no effect.
+func (o *OutputStream) Close() {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	if !o.closed {
+		o.closed = true
+		o.notify()
+	}
+
+	if o.readers == 0 {
+		o.release()
+	}
+}
+
+// Registers a reader, which must call Unfollow when it is done. Returns false
+// if the output was already released; it must then be taken from the report.
+func (o *OutputStream) Follow() bool {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	if o.released {
+		return false
+	}
+
+	o.readers ++
+	return true
+}
+
+func (o *OutputStream) Unfollow() {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	o.readers --
+	if o.closed && o.readers == 0 {
+		o.release()
+	}
+}
+
+// Drops all chunks. Must be called with the lock held.
+func (o *OutputStream) release() {
+	o.dropped += len(o.chunks)
+	o.chunks = nil
+	o.size = 0
+	o.released = true
+}
+
+// Wakes up all waiting readers. Must be called with the lock held.
+func (o *OutputStream) notify() {
+	close(o.changed)
+	o.changed = make(chan struct{})
+}
+
+// Returns the chunks starting at position "from", the position to continue
+// reading at, and whether the stream is closed. Chunks that were dropped due
+// to the limit are left out. If there are no new chunks and the stream is not
+// closed yet, the returned channel is closed as soon as this changes.
+func (o *OutputStream) Read(from int) ([]OutputChunk, int, bool, <-chan struct{}) {
+	o.lock.RLock()
+	defer o.lock.RUnlock()
+
+	if from < o.dropped {
+		from = o.dropped
+	}
+
+	var chunks []OutputChunk
+	if from - o.dropped < len(o.chunks) {
+		chunks = o.chunks[from - o.dropped:]
+	}
+
+	return chunks, o.dropped + len(o.chunks), o.closed, o.changed
+}
@@ -0,0 +1,120 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOutputStreamReplaysEarlierChunksToLateReaders(t *testing.T) {
+	stream := NewOutputStream()
+	stream.Write("node1", STREAM_STDOUT, []byte("first"))
+	stream.Write("node2", STREAM_STDERR, []byte("second"))
+
+	chunks, next, closed, _ := stream.Read(0)
+
+	if len(chunks) != 2 || next != 2 || closed {
+		t.Fatalf("Expected 2 chunks of an open stream, got %d (next %d, closed %v)", len(chunks), next, closed)
+	}
+
+	if string(chunks[0].Data) != "first" || chunks[0].Node != "node1" || string(chunks[1].Data) != "second" || chunks[1].Stream != STREAM_STDERR {
+		t.Errorf("Unexpected chunks %+v", chunks)
+	}
+
+	if chunks, _, _, _ := stream.Read(next); len(chunks) != 0 {
+		t.Errorf("Expected no new chunks, got %d", len(chunks))
+	}
+}
+
+func TestOutputStreamSkipsDroppedChunks(t *testing.T) {
+	stream := NewOutputStream()
+	stream.SetLimit(10)
+
+	stream.Write("node1", STREAM_STDOUT, []byte("aaaaa"))
+	stream.Write("node1", STREAM_STDOUT, []byte("bbbbb"))
+	stream.Write("node1", STREAM_STDOUT, []byte("ccccc"))
+
+	chunks, next, _, _ := stream.Read(0)
+
+	if len(chunks) != 2 || string(chunks[0].Data) != "bbbbb" || string(chunks[1].Data) != "ccccc" {
+		t.Fatalf("Expected the last 2 chunks, got %+v", chunks)
+	}
+
+	// Positions keep counting the dropped chunks, so readers can tell that
+	// output was skipped
+	if next != 3 {
+		t.Errorf("Expected next position 3, got %d", next)
+	}
+
+	if next - len(chunks) != 1 {
+		t.Errorf("Expected 1 dropped chunk before the returned chunks")
+	}
+}
+
+func TestOutputStreamCloseWakesBlockedReaders(t *testing.T) {
+	stream := NewOutputStream()
+	stream.Follow()
+
+	_, next, _, changed := stream.Read(0)
+
+	woken := make(chan bool)
+	go func() {
+		<-changed
+		_, _, closed, _ := stream.Read(next)
+		woken <- closed
+	}()
+
+	stream.Close()
+
+	select {
+	case closed := <-woken:
+		if !closed {
+			t.Error("Expected the stream to be closed")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Reader was not woken by Close")
+	}
+
+	stream.Write("node1", STREAM_STDOUT, []byte("late"))
+	if chunks, _, _, _ := stream.Read(0); len(chunks) != 0 {
+		t.Errorf("Expected writes after Close to be ignored, got %d chunks", len(chunks))
+	}
+}
+
+func TestOutputStreamReleasesOutputWhenReadersAreDone(t *testing.T) {
+	stream := NewOutputStream()
+	stream.Write("node1", STREAM_STDOUT, []byte("output"))
+
+	if !stream.Follow() {
+		t.Fatal("Expected to follow an open stream")
+	}
+
+	stream.Close()
+
+	if chunks, _, _, _ := stream.Read(0); len(chunks) != 1 {
+		t.Errorf("Expected output to be kept while it is read, got %d chunks", len(chunks))
+	}
+
+	stream.Unfollow()
+
+	if chunks, _, _, _ := stream.Read(0); len(chunks) != 0 {
+		t.Errorf("Expected output to be released, got %d chunks", len(chunks))
+	}
+
+	if stream.Follow() {
+		t.Error("Expected released stream not to be followed")
+	}
+}
+
+func TestOutputStreamAlwaysHasLimit(t *testing.T) {
+	stream := NewOutputStream()
+	stream.SetLimit(0)
+
+	chunk := make([]byte, 1024)
+	for i := 0; i < DefaultOutputStreamLimit / 1024 + 10; i ++ {
+		stream.Write("node1", STREAM_STDOUT, chunk)
+	}
+
+	if chunks, _, _, _ := stream.Read(0); len(chunks) * 1024 > DefaultOutputStreamLimit {
+		t.Errorf("Expected at most %d bytes to be kept, got %d", DefaultOutputStreamLimit, len(chunks) * 1024)
+	}
+}
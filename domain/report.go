@@ -3,9 +3,23 @@ package domain
 import (
 	"time"
 	"fmt"
+	"sync"
 	"github.com/twinj/uuid"
 )
 
+const (
+	REPORT_SUCCESS = "success"
+	REPORT_FAILED = "failed"
+	REPORT_SKIPPED = "skipped"
+	REPORT_CANCELLED = "cancelled"
+)
+
+const (
+	RUN_QUEUED = "queued"
+	RUN_RUNNING = "running"
+	RUN_FINISHED = "finished"
+)
+
 type DurationJson struct {
 	Milliseconds float64 `json:"milliseconds"`
 	String string `json:"string"`
@@ -21,6 +35,12 @@ type RunReportJson struct {
 	Time TimePairJson `json:"time"`
 	Duration DurationJson `json:"duration"`
 	Success bool `json:"success"`
+	Status string `json:"status"`
+	Reason string `json:"reason,omitempty"`
+	CatchUp bool `json:"catch_up"`
+	ScheduledFor string `json:"scheduled_for,omitempty"`
+	TriggeredBy string `json:"triggered_by,omitempty"`
+	Manual bool `json:"manual"`
 	Items []RunReportItemJson `json:"items"`
 }
 
@@ -28,8 +48,21 @@ type RunReportItemJson struct {
 	Time TimePairJson `json:"time"`
 	Duration DurationJson `json:"duration"`
 	Success bool `json:"success"`
+	TimedOut bool `json:"timed_out"`
+	Cancelled bool `json:"cancelled"`
+	Skipped bool `json:"skipped"`
+	ExitCode *int `json:"exit_code"`
+	Signal string `json:"signal,omitempty"`
+	StatusCode int `json:"status_code,omitempty"`
 	Output string `json:"output"`
+	Stderr string `json:"stderr"`
+	Combined []OutputLineJson `json:"combined,omitempty"`
+	Truncated bool `json:"truncated"`
+	OutputFile string `json:"output_file,omitempty"`
+	StderrFile string `json:"stderr_file,omitempty"`
 	Node string `json:"node"`
+	Attempt int `json:"attempt"`
+	PreviousAttempts []RunReportItemJson `json:"previous_attempts,omitempty"`
 }
 
 
@@ -60,16 +93,115 @@ type RunReport struct {
 	Job *Job
 	Time TimePair
 	Items []RunReportItem
+	Skipped bool
+	Failed bool
+	Reason string
+
+	// Set for runs that replay a run missed while distcrond was down
+	CatchUp bool
+	ScheduledFor time.Time
+
+	// ID of the upstream run that triggered this run
+	TriggeredBy string
+
+	// Set for runs that were triggered using the REST API
+	Manual bool
+
+	phase string
+	phaseLock sync.RWMutex
+
+	// Output of the run, for following it while the run is active
+	Live *OutputStream
+
+	abort chan struct{}
+	abortOnce sync.Once
+}
+
+func NewRunReport(job *Job) *RunReport {
+	return &RunReport{
+		Id: uuid.Formatter(uuid.NewV4(), uuid.CleanHyphen),
+		Job: job,
+		phase: RUN_QUEUED,
+		Live: NewOutputStream(),
+		abort: make(chan struct{}),
+	}
+}
+
+// Returns whether the run is queued, running or finished.
+func (r *RunReport) Phase() string {
+	r.phaseLock.RLock()
+	defer r.phaseLock.RUnlock()
+
+	return r.phase
+}
+
+func (r *RunReport) SetPhase(phase string) {
+	r.phaseLock.Lock()
+	defer r.phaseLock.Unlock()
+
+	r.phase = phase
+
+	if phase == RUN_FINISHED && r.Live != nil {
+		r.Live.Close()
+	}
 }
 
 func (r *RunReport) Initialize(job *Job, nodeCount int) {
-	r.Id = uuid.Formatter(uuid.NewV4(), uuid.CleanHyphen)
+	if len(r.Id) == 0 {
+		r.Id = uuid.Formatter(uuid.NewV4(), uuid.CleanHyphen)
+	}
+
 	r.Time.Start = time.Now()
 	r.Job = job
 	r.Items = make([]RunReportItem, nodeCount)
 
+	if r.Live != nil {
+		r.Live.SetLimit(job.MaxOutputBytes * nodeCount)
+	}
+
 	for i := 0; i < nodeCount; i ++ {
 		r.Items[i].Id = uuid.Formatter(uuid.NewV4(), uuid.CleanHyphen)
+		r.Items[i].Attempt = 1
+		r.Items[i].Abort = r.abort
+		r.Items[i].Live = r.Live
+	}
+}
+
+// Marks a run as skipped. Skipped runs never executed anything.
+func (r *RunReport) Skip(reason string) {
+	r.Time.Start = time.Now()
+	r.Time.Stop = r.Time.Start
+	r.Skipped = true
+	r.Reason = reason
+}
+
+// Marks a run as failed before anything was executed on the nodes, for
+// example because no node was available.
+func (r *RunReport) Fail(reason string) {
+	now := time.Now()
+	if r.Time.Start.IsZero() {
+		r.Time.Start = now
+	}
+	r.Time.Stop = now
+	r.Failed = true
+	r.Reason = reason
+}
+
+// Cancels a run. Commands that are still running are killed.
+func (r *RunReport) Cancel() {
+	r.abortOnce.Do(func() {
+		if r.abort != nil {
+			close(r.abort)
+		}
+	})
+}
+
+func (r *RunReport) IsCancelled() bool {
+	select {
+	case <-r.abort:
+		return true
+	default:
+		return false
 	}
 }
 
@@ -85,7 +217,24 @@ func (r *RunReport) successOrFail() string {
 	}
 }
 
+func (r *RunReport) Status() string {
+	switch {
+	case r.Skipped:
+		return REPORT_SKIPPED
+	case r.IsCancelled():
+		return REPORT_CANCELLED
+	case r.Success():
+		return REPORT_SUCCESS
+	default:
+		return REPORT_FAILED
+	}
+}
+
 func (r *RunReport) Success() bool {
+	if r.Skipped || r.Failed || r.IsCancelled() {
+		return false
+	}
+
 	success := true
 	for _, i := range r.Items {
 		success = success && i.Success
@@ -105,6 +254,13 @@ func (r *RunReport) ToJson() RunReportJson {
 
 	dur := r.Duration()
 
+	var scheduledFor string
+	if !r.ScheduledFor.IsZero() {
+		if text, err := r.ScheduledFor.MarshalText(); err == nil {
+			scheduledFor = string(text)
+		}
+	}
+
 	return RunReportJson{
 		Job: r.Job.Name,
 		Time: r.Time.ToJson(),
@@ -113,6 +269,12 @@ func (r *RunReport) ToJson() RunReportJson {
 			String: dur.String(),
 		},
 		Success: r.Success(),
+		Status: r.Status(),
+		Reason: r.Reason,
+		CatchUp: r.CatchUp,
+		ScheduledFor: scheduledFor,
+		TriggeredBy: r.TriggeredBy,
+		Manual: r.Manual,
 		Items: items,
 	}
 }
@@ -125,18 +287,105 @@ type RunReportItem struct {
 	Id string
 	Time TimePair
 	Success bool
+	TimedOut bool
+	Cancelled bool
+	Skipped bool
+	ExitCode *int
+	Signal string
+
+	// The response status, for requests to HTTP nodes
+	StatusCode int
 	Output string
+	Stderr string
+	Combined []OutputLine
 	Node *Node
+
+	// Set when the output exceeded the job's limit and was truncated
+	Truncated bool
+
+	// Files that the full output was written to, if the job spools its output
+	OutputFile string
+	StderrFile string
+
+	Attempt int
+	PreviousAttempts []RunReportItem
+
+	// Closed when the run is cancelled
+	Abort <-chan struct{}
+
+	// Receives the output while the command is running
+	Live *OutputStream
 }
 
 func (i *RunReportItem) Summary() string {
 	date, _ := i.Time.Start.MarshalText()
-	return fmt.Sprintf("On %s at %s (duration %s): %s, %d bytes of output", i.Node.Name, date, i.Duration().String(), i.successOrFail(), len(i.Output))
+	return fmt.Sprintf("On %s at %s (duration %s, attempt %d): %s, %d bytes of output", i.NodeName(), date, i.Duration().String(), i.Attempt, i.successOrFail(), len(i.Output))
+}
+
+// Records how the command terminated: either with an exit code, or by a
+// signal (in which case there is no exit code).
+func (i *RunReportItem) SetExitCode(code int) {
+	i.ExitCode = &code
+	i.Signal = ""
+}
+
+func (i *RunReportItem) SetSignal(signal string) {
+	i.ExitCode = nil
+	i.Signal = signal
+}
+
+// Marks the item as not run on its node at all.
+func (i *RunReportItem) Skip(node *Node, reason string) {
+	i.Node = node
+	i.Time.Start = time.Now()
+	i.Time.Stop = i.Time.Start
+	i.Success = false
+	i.Skipped = true
+	i.Output = reason
+}
+
+// Returns the name of the node that the item was run on, or an empty string
+// if no node could be found.
+func (i *RunReportItem) NodeName() string {
+	if i.Node == nil {
+		return ""
+	}
+	return i.Node.Name
+}
+
+// Moves the result of the current attempt into the attempt history and
+// resets the item for the next attempt.
+func (i *RunReportItem) BeginNextAttempt() {
+	previous := *i
+	previous.PreviousAttempts = nil
+
+	i.PreviousAttempts = append(i.PreviousAttempts, previous)
+	i.Id = uuid.Formatter(uuid.NewV4(), uuid.CleanHyphen)
+	i.Time = TimePair{}
+	i.Success = false
+	i.TimedOut = false
+	i.Cancelled = false
+	i.ExitCode = nil
+	i.Signal = ""
+	i.StatusCode = 0
+	i.Output = ""
+	i.Stderr = ""
+	i.Combined = nil
+	i.Truncated = false
+	i.OutputFile = ""
+	i.StderrFile = ""
+	i.Attempt = previous.Attempt + 1
 }
 
 func (i *RunReportItem) successOrFail() string {
 	if i.Success {
 		return "success"
+	} else if i.TimedOut {
+		return "TIMEOUT"
+	} else if i.Cancelled {
+		return "CANCELLED"
+	} else if i.Skipped {
+		return "SKIPPED"
 	} else {
 		return "FAIL"
 	}
@@ -147,15 +396,75 @@ func (i *RunReportItem) Duration() time.Duration {
 }
 
 func (i *RunReportItem) ToJson() RunReportItemJson {
+	var previous []RunReportItemJson
+	if len(i.PreviousAttempts) > 0 {
+		previous = make([]RunReportItemJson, len(i.PreviousAttempts))
+		for j, attempt := range i.PreviousAttempts {
+			previous[j] = attempt.ToJson()
+		}
+	}
+
+	var combined []OutputLineJson
+	if len(i.Combined) > 0 {
+		combined = make([]OutputLineJson, len(i.Combined))
+		for j, line := range i.Combined {
+			combined[j] = line.ToJson()
+		}
+	}
+
 	dur := i.Duration()
 	return RunReportItemJson{
-		Node: i.Node.Name,
+		Node: i.NodeName(),
 		Time: i.Time.ToJson(),
 		Duration: DurationJson{
 			Milliseconds: float64(dur.Nanoseconds()) / float64(time.Millisecond),
 			String: dur.String(),
 		},
 		Success: i.Success,
+		TimedOut: i.TimedOut,
+		Cancelled: i.Cancelled,
+		Skipped: i.Skipped,
+		ExitCode: i.ExitCode,
+		Signal: i.Signal,
+		StatusCode: i.StatusCode,
 		Output: i.Output,
+		Stderr: i.Stderr,
+		Combined: combined,
+		Truncated: i.Truncated,
+		OutputFile: i.OutputFile,
+		StderrFile: i.StderrFile,
+		Attempt: i.Attempt,
+		PreviousAttempts: previous,
+	}
+}
+
+
+// Output Line
+// ===========
+
+const (
+	STREAM_STDOUT = "stdout"
+	STREAM_STDERR = "stderr"
+)
+
+// A line of output, as part of the combined output of both streams.
+type OutputLine struct {
+	Time time.Time
+	Stream string
+	Text string
+}
+
+type OutputLineJson struct {
+	Time string `json:"time"`
+	Stream string `json:"stream"`
+	Text string `json:"text"`
+}
+
+func (l OutputLine) ToJson() OutputLineJson {
+	t, _ := l.Time.MarshalText()
+	return OutputLineJson{
+		Time: string(t),
+		Stream: l.Stream,
+		Text: l.Text,
 	}
 }
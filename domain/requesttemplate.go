@@ -0,0 +1,52 @@
+package domain
+
+import (
+	"encoding/json"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// The values that are available in the URL and body templates of HTTP nodes,
+// like "https://example.com/hooks/{{.Job}}?run={{.Run}}".
+type RequestTemplateData struct {
+	Job string
+	Node string
+	Run string
+	Command string
+	Args []string
+	Env map[string]string
+	Time time.Time
+}
+
+var requestTemplateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		encoded, err := json.Marshal(v)
+		return string(encoded), err
+	},
+}
+
+func ParseRequestTemplate(text string) (*template.Template, error) {
+	return template.New("request").Funcs(requestTemplateFuncs).Option("missingkey=error").Parse(text)
+}
+
+func NewRequestTemplateData(job *Job, report *RunReportItem) RequestTemplateData {
+	args := job.Command.Command()
+	command := strings.Join(args, " ")
+
+	// Pass shell commands as they were written, not wrapped in "sh -c"
+	if shell, ok := job.Command.(ShellCommand); ok {
+		command = shell.shellCommand
+		args = []string{command}
+	}
+
+	return RequestTemplateData{
+		Job: job.Name,
+		Node: report.NodeName(),
+		Run: report.Id,
+		Command: command,
+		Args: args,
+		Env: job.Environment,
+		Time: time.Now(),
+	}
+}
@@ -0,0 +1,89 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	RETRY_ON_FAILURE = "failure"
+	RETRY_ON_NODE_DOWN = "node_down"
+	RETRY_ON_TIMEOUT = "timeout"
+)
+
+// Upper bound for the exponent of the backoff, to prevent overflows with
+// ridiculously high retry counts.
+const maxBackoffExponent = 16
+
+type RetryPolicy struct {
+	Retries int
+	Backoff time.Duration
+	On []string
+}
+
+func NewRetryPolicyFromJson(json JobJson) (RetryPolicy, error) {
+	policy := RetryPolicy{
+		Retries: json.Retries,
+		On: json.RetryOn,
+	}
+
+	if len(json.RetryBackoff) > 0 {
+		backoff, err := time.ParseDuration(json.RetryBackoff)
+		if err != nil {
+			return RetryPolicy{}, errors.New(fmt.Sprintf("Invalid retry backoff '%s': %s", json.RetryBackoff, err))
+		}
+		policy.Backoff = backoff
+	}
+
+	if len(policy.On) == 0 {
+		policy.On = []string{RETRY_ON_FAILURE, RETRY_ON_NODE_DOWN, RETRY_ON_TIMEOUT}
+	}
+
+	return policy, nil
+}
+
+func (p RetryPolicy) IsValid() error {
+	if p.Retries < 0 {
+		return errors.New("'Retries' must not be negative")
+	}
+
+	if p.Backoff < 0 {
+		return errors.New("'RetryBackoff' must not be negative")
+	}
+
+	for _, condition := range p.On {
+		if condition != RETRY_ON_FAILURE && condition != RETRY_ON_NODE_DOWN && condition != RETRY_ON_TIMEOUT {
+			return errors.New(fmt.Sprintf("Unknown retry condition '%s' (must be '%s', '%s' or '%s')", condition, RETRY_ON_FAILURE, RETRY_ON_NODE_DOWN, RETRY_ON_TIMEOUT))
+		}
+	}
+
+	return nil
+}
+
+// Determines if a failed attempt should be retried. Attempts are counted
+// starting from 1; the condition is one of the RETRY_ON_* constants.
+func (p RetryPolicy) ShouldRetry(attempt int, condition string) bool {
+	if attempt > p.Retries {
+		return false
+	}
+
+	for _, c := range p.On {
+		if c == condition {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Computes the time to wait after the given (failed) attempt. The backoff
+// doubles with each attempt.
+func (p RetryPolicy) BackoffFor(attempt int) time.Duration {
+	exponent := attempt - 1
+	if exponent > maxBackoffExponent {
+		exponent = maxBackoffExponent
+	}
+
+	return p.Backoff * time.Duration(1 << uint(exponent))
+}
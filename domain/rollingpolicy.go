@@ -0,0 +1,69 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+type RollingPolicyJson struct {
+	BatchSize int `json:"batch_size"`
+	Pause string `json:"pause"`
+	MaxFailures int `json:"max_failures"`
+}
+
+// Describes how a job with the "all" policy is rolled out: the job is run on
+// "BatchSize" nodes at a time, with a pause between the batches. As soon as
+// the job failed on "MaxFailures" nodes, the rollout is stopped and the nodes
+// that were not run on yet are skipped.
+type RollingPolicy struct {
+	BatchSize int
+	Pause time.Duration
+	MaxFailures int
+}
+
+// Returns nil if the job is not rolled out in batches.
+func NewRollingPolicyFromJson(json JobJson) (*RollingPolicy, error) {
+	if json.Rolling == nil {
+		return nil, nil
+	}
+
+	policy := &RollingPolicy{
+		BatchSize: json.Rolling.BatchSize,
+		MaxFailures: json.Rolling.MaxFailures,
+	}
+
+	if len(json.Rolling.Pause) > 0 {
+		pause, err := time.ParseDuration(json.Rolling.Pause)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Invalid rolling pause '%s': %s", json.Rolling.Pause, err))
+		}
+		policy.Pause = pause
+	}
+
+	if policy.BatchSize == 0 {
+		policy.BatchSize = 1
+	}
+
+	if policy.MaxFailures == 0 {
+		policy.MaxFailures = 1
+	}
+
+	return policy, nil
+}
+
+func (p *RollingPolicy) IsValid() error {
+	if p.BatchSize < 1 {
+		return errors.New("'BatchSize' must be at least 1")
+	}
+
+	if p.Pause < 0 {
+		return errors.New("'Pause' must not be negative")
+	}
+
+	if p.MaxFailures < 1 {
+		return errors.New("'MaxFailures' must be at least 1")
+	}
+
+	return nil
+}
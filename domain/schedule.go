@@ -0,0 +1,105 @@
+package domain
+
+import (
+	"time"
+	"github.com/robfig/cron"
+)
+
+// Bit mask of all hours of a day, as used by cron.SpecSchedule
+const allHours = 1 << 24 - 1
+
+// A schedule that is evaluated in a specific time zone.
+//
+// Cron expressions with fixed hours are adjusted for daylight saving time
+// transitions, just like Vixie cron does: runs that fall into an hour that is
+// skipped are started right after the transition, and runs that fall into an
+// hour that is repeated are started only once.
+type ZonedSchedule struct {
+	Schedule cron.Schedule
+	Location *time.Location
+}
+
+func ParseSchedule(spec string, location *time.Location) (cron.Schedule, error) {
+	schedule, err := cron.Parse(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	return ZonedSchedule{schedule, location}, nil
+}
+
+func (z ZonedSchedule) Next(t time.Time) time.Time {
+	t = t.In(z.Location)
+	next := z.Schedule.Next(t)
+
+	spec, ok := z.Schedule.(*cron.SpecSchedule)
+	if !ok || next.IsZero() || spec.Hour & allHours == allHours {
+		return next
+	}
+
+	if skipped, ok := z.skippedRun(t, next); ok {
+		return skipped
+	}
+
+	if z.isRepeatedRun(t, next) {
+		return z.Next(next)
+	}
+
+	return next
+}
+
+// Checks if a run was scheduled for a wall clock time between "t" and "next"
+// that did not exist because the clocks were set forward. If so, returns the
+// time of the transition.
+func (z ZonedSchedule) skippedRun(t time.Time, next time.Time) (time.Time, bool) {
+	_, offset := t.Zone()
+
+	// Evaluate the schedule as if no transition had happened
+	candidate := z.Schedule.Next(t.In(time.FixedZone("", offset)))
+	if !candidate.Before(next) {
+		return time.Time{}, false
+	}
+
+	if _, candidateOffset := candidate.In(z.Location).Zone(); candidateOffset <= offset {
+		return time.Time{}, false
+	}
+
+	if wallClockExists(candidate, z.Location) {
+		return time.Time{}, false
+	}
+
+	// Find the exact time of the transition using bisection
+	low, high := t, candidate
+	for high.Sub(low) > time.Second {
+		middle := low.Add(high.Sub(low) / 2)
+		if _, o := middle.Zone(); o == offset {
+			low = middle
+		} else {
+			high = middle
+		}
+	}
+
+	return high.Truncate(time.Second).In(z.Location), true
+}
+
+// Checks if the wall clock time of "next" already occurred once before (and
+// not after "t"), because the clocks were set back.
+func (z ZonedSchedule) isRepeatedRun(t time.Time, next time.Time) bool {
+	_, offset := next.Zone()
+	_, offsetBefore := next.Add(-3 * time.Hour).Zone()
+
+	if offsetBefore <= offset {
+		return false
+	}
+
+	earlier := next.Add(-time.Duration(offsetBefore - offset) * time.Second)
+	return sameWallClock(earlier, next) && !earlier.After(t)
+}
+
+func wallClockExists(t time.Time, location *time.Location) bool {
+	return sameWallClock(time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, location), t)
+}
+
+func sameWallClock(a time.Time, b time.Time) bool {
+	return a.Year() == b.Year() && a.YearDay() == b.YearDay() && a.Hour() == b.Hour() && a.Minute() == b.Minute() && a.Second() == b.Second()
+}
@@ -0,0 +1,73 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func mustLoadLocation(name string, t *testing.T) *time.Location {
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("Time zone %s not available: %s", name, err)
+	}
+	return location
+}
+
+func TestZonedScheduleEvaluatesScheduleInTimeZone(t *testing.T) {
+	newYork := mustLoadLocation("America/New_York", t)
+	schedule, _ := ParseSchedule("0 0 9 * * *", newYork)
+
+	next := schedule.Next(time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC))
+	expected := time.Date(2026, 6, 1, 13, 0, 0, 0, time.UTC)
+
+	if !next.Equal(expected) {
+		t.Errorf("Expected %s, got %s", expected, next)
+	}
+}
+
+func TestZonedScheduleRunsSkippedRunsAfterTransition(t *testing.T) {
+	berlin := mustLoadLocation("Europe/Berlin", t)
+	schedule, _ := ParseSchedule("0 30 2 * * *", berlin)
+
+	next := schedule.Next(time.Date(2026, 3, 28, 12, 0, 0, 0, berlin))
+	expected := time.Date(2026, 3, 29, 3, 0, 0, 0, berlin)
+
+	if !next.Equal(expected) {
+		t.Errorf("Expected %s, got %s", expected, next)
+	}
+
+	next = schedule.Next(next)
+	expected = time.Date(2026, 3, 30, 2, 30, 0, 0, berlin)
+
+	if !next.Equal(expected) {
+		t.Errorf("Expected %s, got %s", expected, next)
+	}
+}
+
+func TestZonedScheduleRunsRepeatedRunsOnlyOnce(t *testing.T) {
+	berlin := mustLoadLocation("Europe/Berlin", t)
+	schedule, _ := ParseSchedule("0 30 2 * * *", berlin)
+
+	first := schedule.Next(time.Date(2026, 10, 24, 12, 0, 0, 0, berlin))
+	second := schedule.Next(first)
+	expected := time.Date(2026, 10, 26, 2, 30, 0, 0, berlin)
+
+	if !second.Equal(expected) {
+		t.Errorf("Expected %s, got %s (first run at %s)", expected, second, first)
+	}
+}
+
+func TestZonedScheduleKeepsHourlyRunsDuringTransition(t *testing.T) {
+	berlin := mustLoadLocation("Europe/Berlin", t)
+	schedule, _ := ParseSchedule("0 0 * * * *", berlin)
+
+	start := time.Date(2026, 10, 25, 0, 30, 0, 0, berlin)
+	next := start
+	for i := 0; i < 4; i ++ {
+		next = schedule.Next(next)
+	}
+
+	if next.Sub(start) != 3*time.Hour + 30*time.Minute {
+		t.Errorf("Expected four hourly runs within 3.5 hours, last one at %s", next)
+	}
+}
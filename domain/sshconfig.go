@@ -0,0 +1,155 @@
+package domain
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+)
+
+// The subset of an OpenSSH client configuration file (~/.ssh/config) that is
+// relevant for connecting to nodes. Just like in OpenSSH, the first value that
+// is found for a host wins.
+type SshClientConfig struct {
+	blocks []sshConfigBlock
+}
+
+type sshConfigBlock struct {
+	patterns []string
+	options map[string]string
+}
+
+// The options that apply to a host.
+type SshHostConfig struct {
+	HostName string
+	User string
+	Port string
+	IdentityFile string
+	ProxyJump string
+}
+
+// Parses an OpenSSH client configuration. "Match" blocks and "Include"
+// directives are not supported and ignored.
+func ParseSshClientConfig(r io.Reader) (*SshClientConfig, error) {
+	config := &SshClientConfig{}
+
+	// Options before the first "Host" line apply to all hosts
+	block := &sshConfigBlock{patterns: []string{"*"}, options: make(map[string]string)}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+
+		key, value := splitSshConfigLine(line)
+
+		switch key {
+		case "host":
+			config.blocks = append(config.blocks, *block)
+			block = &sshConfigBlock{patterns: strings.Fields(value), options: make(map[string]string)}
+		case "match":
+			config.blocks = append(config.blocks, *block)
+			block = &sshConfigBlock{options: make(map[string]string)}
+		default:
+			if _, ok := block.options[key]; !ok {
+				block.options[key] = strings.Trim(value, "\"")
+			}
+		}
+	}
+
+	config.blocks = append(config.blocks, *block)
+
+	return config, scanner.Err()
+}
+
+// Splits a line into the (lower case) keyword and its value. Both "Key value"
+// and "Key=value" are allowed.
+func splitSshConfigLine(line string) (string, string) {
+	end := strings.IndexAny(line, " \t=")
+	if end < 0 {
+		return strings.ToLower(line), ""
+	}
+
+	value := strings.TrimSpace(line[end:])
+	value = strings.TrimSpace(strings.TrimPrefix(value, "="))
+
+	return strings.ToLower(line[:end]), value
+}
+
+// Collects the options for a host.
+func (c *SshClientConfig) Lookup(host string) SshHostConfig {
+	options := make(map[string]string)
+
+	for _, block := range c.blocks {
+		if !sshHostMatch(block.patterns, host) {
+			continue
+		}
+
+		for key, value := range block.options {
+			if _, ok := options[key]; !ok {
+				options[key] = value
+			}
+		}
+	}
+
+	return SshHostConfig{
+		HostName: strings.Replace(options["hostname"], "%h", host, -1),
+		User: options["user"],
+		Port: options["port"],
+		IdentityFile: options["identityfile"],
+		ProxyJump: options["proxyjump"],
+	}
+}
+
+func sshHostMatch(patterns []string, host string) bool {
+	matched := false
+	for _, pattern := range patterns {
+		if strings.HasPrefix(pattern, "!") {
+			if WildcardMatch(pattern[1:], host) {
+				return false
+			}
+		} else if WildcardMatch(pattern, host) {
+			matched = true
+		}
+	}
+
+	return matched
+}
+
+// Matches a string against a pattern with "*" and "?" wildcards, as used by
+// OpenSSH.
+func WildcardMatch(pattern string, s string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for i := len(s); i >= 0; i -- {
+				if WildcardMatch(pattern[1:], s[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(s) == 0 {
+				return false
+			}
+		default:
+			if len(s) == 0 || pattern[0] != s[0] {
+				return false
+			}
+		}
+
+		pattern, s = pattern[1:], s[1:]
+	}
+
+	return len(s) == 0
+}
+
+// Replaces a leading "~" with the home directory.
+func ExpandHome(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		return os.Getenv("HOME") + path[1:]
+	}
+	return path
+}
@@ -0,0 +1,55 @@
+package domain
+
+import (
+	"strings"
+	"testing"
+)
+
+const testSshConfig = `
+User fallback
+
+Host db*
+    HostName %h.internal
+    Port 2222
+    ProxyJump jump@bastion
+
+Host bastion
+    HostName bastion.example.com
+    IdentityFile=~/.ssh/bastion
+`
+
+func TestSetDefaultsResolvesHostsFromSshConfig(t *testing.T) {
+	config, err := ParseSshClientConfig(strings.NewReader(testSshConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	options := ConnectionOptions{SshHost: "db01", SshUser: "postgres"}
+	options.SetDefaults(CONN_SSH, config)
+
+	if options.SshHost != "db01.internal:2222" {
+		t.Errorf("Unexpected host %s", options.SshHost)
+	}
+
+	if options.SshUser != "postgres" {
+		t.Errorf("Unexpected user %s", options.SshUser)
+	}
+
+	if len(options.SshJumpHosts) != 1 {
+		t.Fatalf("Expected one jump host, got %d", len(options.SshJumpHosts))
+	}
+
+	jump := options.SshJumpHosts[0]
+	if jump.SshHost != "bastion.example.com:22" || jump.SshUser != "jump" || !strings.HasSuffix(jump.SshKeyFile, "/.ssh/bastion") {
+		t.Errorf("Unexpected jump host %+v", jump)
+	}
+}
+
+func TestSetDefaultsAddsDefaultPort(t *testing.T) {
+	options := ConnectionOptions{SshHost: "example.com"}
+	options.SetDefaults(CONN_SSH, nil)
+
+	if options.SshHost != "example.com:22" || options.SshUser != "root" {
+		t.Errorf("Unexpected options %+v", options)
+	}
+}
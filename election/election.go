@@ -0,0 +1,45 @@
+package election
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// A lease on the leadership. The instance that holds a lease that has not
+// expired yet is the leader.
+type Lease struct {
+	Instance string `json:"instance"`
+	Address string `json:"address"`
+	Acquired time.Time `json:"acquired"`
+	Expires time.Time `json:"expires"`
+}
+
+func (l Lease) IsHeld(now time.Time) bool {
+	return len(l.Instance) > 0 && now.Before(l.Expires)
+}
+
+// Stores the leadership lease, shared between all instances.
+type LockBackend interface {
+	// Acquires the lease for the candidate (or renews it, when the candidate
+	// is already holding it) unless another instance holds the lease. Returns
+	// the lease that is valid after the call.
+	Acquire(candidate Lease, ttl time.Duration) (Lease, error)
+
+	// Gives up the lease, if it is held by the instance.
+	Release(instance string) error
+}
+
+type LockBackendConfiguration interface {
+	LeaderElection() string
+	LeaseFile() string
+}
+
+func BuildLockBackend(config LockBackendConfiguration) (LockBackend, error) {
+	switch config.LeaderElection() {
+	case "file":
+		return NewFileLockBackend(config.LeaseFile()), nil
+	default:
+		return nil, errors.New(fmt.Sprintf("Unknown lock backend type: '%s'", config.LeaderElection()))
+	}
+}
@@ -0,0 +1,189 @@
+package election
+
+import (
+	"sync"
+	"time"
+	"github.com/martin-helmich/distcrond/logging"
+)
+
+// Takes part in the leader election by periodically trying to acquire (or
+// renew) the lease. The lease is renewed three times per TTL; a leader that
+// cannot renew its lease steps down before the lease expires, so that no two
+// instances consider themselves leader at the same time.
+type Elector struct {
+	backend LockBackend
+	self Lease
+	ttl time.Duration
+
+	lock sync.RWMutex
+	leader Lease
+	isLeader bool
+
+	abort chan bool
+	done chan bool
+
+	// Called when this instance becomes leader, or stops being leader
+	OnElected func()
+	OnDemoted func()
+}
+
+// Time before the lease expires at which a leader that could not renew its
+// lease steps down, as a fraction of the TTL
+const stepDownMargin = 10
+
+func NewElector(backend LockBackend, instance string, address string, ttl time.Duration) *Elector {
+	return &Elector{
+		backend: backend,
+		self: Lease{Instance: instance, Address: address},
+		ttl: ttl,
+		abort: make(chan bool),
+		done: make(chan bool),
+	}
+}
+
+func (e *Elector) Instance() string {
+	return e.self.Instance
+}
+
+func (e *Elector) IsLeader() bool {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+
+	return e.isLeader
+}
+
+// Returns the lease of the current leader. The lease is empty when there is
+// no leader (as far as this instance knows).
+func (e *Elector) Leader() Lease {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+
+	if !e.leader.IsHeld(time.Now()) {
+		return Lease{}
+	}
+	return e.leader
+}
+
+// Takes part in the election until Stop is called.
+func (e *Elector) Run() {
+	logging.Info("Joining leader election as %s", e.self.Instance)
+
+	ticker := time.NewTicker(e.ttl / 3)
+	defer ticker.Stop()
+
+	watchdog := make(chan bool)
+	defer close(watchdog)
+	go e.watchLease(watchdog)
+
+	e.campaign()
+
+	for {
+		select {
+		case <- ticker.C:
+			e.campaign()
+
+		case <- e.abort:
+			if e.IsLeader() {
+				e.demote()
+				if err := e.backend.Release(e.self.Instance); err != nil {
+					logging.Error("Could not release lease: %s", err)
+				}
+			}
+
+			e.done <- true
+			return
+		}
+	}
+}
+
+// Leaves the election. When this instance is the leader, it steps down and
+// releases the lease, so that a standby can take over right away.
+func (e *Elector) Stop() {
+	e.abort <- true
+	<- e.done
+}
+
+func (e *Elector) campaign() {
+	lease, err := e.backend.Acquire(e.self, e.ttl)
+	if err != nil {
+		logging.Error("Could not acquire lease: %s", err)
+
+		// Step down if the lease would expire before the next attempt
+		e.stepDownBefore(e.ttl / 3)
+		return
+	}
+
+	e.lock.Lock()
+	previous := e.leader
+	e.leader = lease
+	e.lock.Unlock()
+
+	if lease.Instance == e.self.Instance {
+		if !e.IsLeader() {
+			e.elect()
+		}
+	} else {
+		if e.IsLeader() {
+			e.demote()
+		}
+		if previous.Instance != lease.Instance {
+			logging.Notice("Instance %s is leader", lease.Instance)
+		}
+	}
+}
+
+// Steps down when the renewal did not succeed before the lease expires. Runs
+// until "stop" is closed.
+func (e *Elector) watchLease(stop <-chan bool) {
+	ticker := time.NewTicker(e.ttl / stepDownMargin)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <- ticker.C:
+			e.stepDownBefore(e.ttl / stepDownMargin)
+		case <- stop:
+			return
+		}
+	}
+}
+
+// Steps down if this instance is leader and its lease expires within "margin".
+func (e *Elector) stepDownBefore(margin time.Duration) {
+	e.lock.RLock()
+	expiring := e.isLeader && time.Now().Add(margin).After(e.leader.Expires)
+	e.lock.RUnlock()
+
+	if expiring {
+		logging.Warning("Lease could not be renewed in time")
+		e.demote()
+	}
+}
+
+func (e *Elector) elect() {
+	e.lock.Lock()
+	changed := !e.isLeader
+	e.isLeader = true
+	e.lock.Unlock()
+
+	if changed {
+		logging.Notice("Elected as leader")
+		if e.OnElected != nil {
+			e.OnElected()
+		}
+	}
+}
+
+func (e *Elector) demote() {
+	e.lock.Lock()
+	changed := e.isLeader
+	e.isLeader = false
+	e.lock.Unlock()
+
+	if changed {
+		logging.Warning("Stepping down as leader")
+		if e.OnDemoted != nil {
+			e.OnDemoted()
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package election
+
+import (
+	"testing"
+	"time"
+	"github.com/martin-helmich/distcrond/logging"
+)
+
+// Grants the first lease and blocks on all renewals.
+type blockingBackend struct {
+	acquired bool
+	block chan bool
+}
+
+func (b *blockingBackend) Acquire(candidate Lease, ttl time.Duration) (Lease, error) {
+	if b.acquired {
+		<- b.block
+	}
+
+	b.acquired = true
+	candidate.Acquired = time.Now()
+	candidate.Expires = candidate.Acquired.Add(ttl)
+	return candidate, nil
+}
+
+func (b *blockingBackend) Release(instance string) error {
+	return nil
+}
+
+func TestElectorStepsDownWhenRenewalBlocks(t *testing.T) {
+	logging.Setup()
+
+	backend := &blockingBackend{block: make(chan bool)}
+	defer close(backend.block)
+
+	demoted := make(chan time.Time, 1)
+	elector := NewElector(backend, "a", "", 300 * time.Millisecond)
+	elector.OnDemoted = func() { demoted <- time.Now() }
+
+	go elector.Run()
+
+	time.Sleep(50 * time.Millisecond)
+	if !elector.IsLeader() {
+		t.Fatal("Expected instance to be leader")
+	}
+
+	expires := elector.Leader().Expires
+
+	select {
+	case at := <- demoted:
+		if at.After(expires) {
+			t.Errorf("Expected to step down before the lease expires at %s, stepped down at %s", expires, at)
+		}
+	case <- time.After(time.Second):
+		t.Fatal("Expected instance to step down")
+	}
+
+	if elector.IsLeader() {
+		t.Error("Expected instance not to be leader anymore")
+	}
+}
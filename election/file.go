@@ -0,0 +1,142 @@
+package election
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+// Interval in which to retry taking the mutex file
+const mutexRetryInterval = 50 * time.Millisecond
+
+// Maximum time to wait for the mutex file when releasing the lease
+const releaseWait = 5 * time.Second
+
+// Stores the lease in a file on a filesystem that is shared between all
+// instances. Changes to the lease file are serialized using a mutex file that
+// is created exclusively, which (unlike flock) also works on NFS.
+type FileLockBackend struct {
+	path string
+	mutexPath string
+}
+
+func NewFileLockBackend(path string) *FileLockBackend {
+	return &FileLockBackend{
+		path: path,
+		mutexPath: path + ".lock",
+	}
+}
+
+func (f *FileLockBackend) Acquire(candidate Lease, ttl time.Duration) (Lease, error) {
+	// Give up well before the next renewal is due, so that a leader that
+	// cannot renew its lease learns about it before the lease expires
+	if err := f.lock(ttl, ttl / 6); err != nil {
+		return Lease{}, err
+	}
+	defer f.unlock()
+
+	current, err := f.read()
+	if err != nil {
+		return Lease{}, err
+	}
+
+	now := time.Now()
+	if current.IsHeld(now) && current.Instance != candidate.Instance {
+		return current, nil
+	}
+
+	candidate.Acquired = now
+	if current.Instance == candidate.Instance && !current.Acquired.IsZero() {
+		candidate.Acquired = current.Acquired
+	}
+	candidate.Expires = now.Add(ttl)
+
+	if err := f.write(candidate); err != nil {
+		return Lease{}, err
+	}
+
+	return candidate, nil
+}
+
+func (f *FileLockBackend) Release(instance string) error {
+	if err := f.lock(time.Minute, releaseWait); err != nil {
+		return err
+	}
+	defer f.unlock()
+
+	current, err := f.read()
+	if err != nil {
+		return err
+	}
+
+	if current.Instance != instance {
+		return nil
+	}
+
+	return os.Remove(f.path)
+}
+
+func (f *FileLockBackend) read() (Lease, error) {
+	lease := Lease{}
+
+	content, err := ioutil.ReadFile(f.path)
+	if os.IsNotExist(err) {
+		return lease, nil
+	} else if err != nil {
+		return lease, err
+	}
+
+	if err := json.Unmarshal(content, &lease); err != nil {
+		return lease, errors.New(fmt.Sprintf("Invalid lease file %s: %s", f.path, err))
+	}
+
+	return lease, nil
+}
+
+// Writes the lease to a temporary file first, so that other instances never
+// see a partially written lease.
+func (f *FileLockBackend) write(lease Lease) error {
+	body, _ := json.Marshal(lease)
+	temp := fmt.Sprintf("%s.%d.tmp", f.path, os.Getpid())
+
+	if err := ioutil.WriteFile(temp, body, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(temp, f.path)
+}
+
+// Takes the mutex file, waiting at most "wait" for it. Mutex files older than
+// "stale" are left over from crashed instances and are removed.
+func (f *FileLockBackend) lock(stale time.Duration, wait time.Duration) error {
+	deadline := time.Now().Add(wait)
+
+	for {
+		file, err := os.OpenFile(f.mutexPath, os.O_WRONLY | os.O_CREATE | os.O_EXCL, 0644)
+		if err == nil {
+			return file.Close()
+		}
+
+		if !os.IsExist(err) {
+			return err
+		}
+
+		if info, sErr := os.Stat(f.mutexPath); sErr == nil && time.Since(info.ModTime()) > stale {
+			os.Remove(f.mutexPath)
+			continue
+		}
+
+		if time.Now().After(deadline) {
+			return errors.New(fmt.Sprintf("Timed out waiting for mutex file %s", f.mutexPath))
+		}
+
+		time.Sleep(mutexRetryInterval)
+	}
+}
+
+func (f *FileLockBackend) unlock() {
+	os.Remove(f.mutexPath)
+}
@@ -0,0 +1,58 @@
+package election
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLeaseIsOnlyAcquiredWhenNotHeldByOtherInstance(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "distcrond-election")
+	defer os.RemoveAll(dir)
+
+	backend := NewFileLockBackend(filepath.Join(dir, "leader.json"))
+
+	lease, err := backend.Acquire(Lease{Instance: "a"}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lease.Instance != "a" {
+		t.Errorf("Expected a to acquire the lease, got %s", lease.Instance)
+	}
+
+	lease, _ = backend.Acquire(Lease{Instance: "b"}, time.Minute)
+	if lease.Instance != "a" {
+		t.Errorf("Expected lease to stay with a, got %s", lease.Instance)
+	}
+
+	renewed, _ := backend.Acquire(Lease{Instance: "a"}, time.Minute)
+	if !renewed.Acquired.Equal(lease.Acquired) || !renewed.Expires.After(lease.Expires) {
+		t.Errorf("Expected lease to be renewed, got %+v", renewed)
+	}
+
+	if err := backend.Release("a"); err != nil {
+		t.Fatal(err)
+	}
+
+	lease, _ = backend.Acquire(Lease{Instance: "b"}, time.Minute)
+	if lease.Instance != "b" {
+		t.Errorf("Expected b to acquire the released lease, got %s", lease.Instance)
+	}
+}
+
+func TestExpiredLeaseIsTakenOver(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "distcrond-election")
+	defer os.RemoveAll(dir)
+
+	backend := NewFileLockBackend(filepath.Join(dir, "leader.json"))
+
+	backend.Acquire(Lease{Instance: "a"}, time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+
+	lease, _ := backend.Acquire(Lease{Instance: "b"}, time.Minute)
+	if lease.Instance != "b" {
+		t.Errorf("Expected b to take over the expired lease, got %s", lease.Instance)
+	}
+}
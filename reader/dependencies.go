@@ -0,0 +1,69 @@
+package reader
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"github.com/martin-helmich/distcrond/domain"
+)
+
+const (
+	unvisited = iota
+	visiting
+	visited
+)
+
+// Checks that jobs only depend on known jobs and that there are no cyclic
+// dependencies. Populates the list of downstream jobs of each job.
+func resolveDependencies(jobs []*domain.Job) error {
+	jobsByName := make(map[string]*domain.Job)
+	for _, job := range jobs {
+		jobsByName[job.Name] = job
+	}
+
+	for _, job := range jobs {
+		for _, upstream := range job.Dependency.Upstream {
+			upstreamJob, ok := jobsByName[upstream]
+			if !ok {
+				return errors.New(fmt.Sprintf("Job %s depends on unknown job %s", job.Name, upstream))
+			}
+
+			upstreamJob.Downstream = append(upstreamJob.Downstream, job.Name)
+		}
+	}
+
+	state := make(map[string]int)
+	path := make([]string, 0, len(jobs))
+
+	var visit func(job *domain.Job) error
+	visit = func(job *domain.Job) error {
+		switch state[job.Name] {
+		case visiting:
+			return errors.New(fmt.Sprintf("Cyclic job dependency: %s -> %s", strings.Join(path, " -> "), job.Name))
+		case visited:
+			return nil
+		}
+
+		state[job.Name] = visiting
+		path = append(path, job.Name)
+
+		for _, downstream := range job.Downstream {
+			if err := visit(jobsByName[downstream]); err != nil {
+				return err
+			}
+		}
+
+		path = path[:len(path) - 1]
+		state[job.Name] = visited
+
+		return nil
+	}
+
+	for _, job := range jobs {
+		if err := visit(job); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -13,15 +13,21 @@ import (
 )
 
 type JobReader struct {
-	validationConfig domain.JobValidationConfig
+	validationConfig JobConfig
 	receiver JobReceiver
 }
 
+type JobConfig interface {
+	domain.JobValidationConfig
+	MaxOutputBytes() int
+	OutputDirectory() string
+}
+
 type JobReceiver interface {
 	AddJob(domain.Job)
 }
 
-func NewJobReader(validationConfig domain.JobValidationConfig, receiver JobReceiver) *JobReader {
+func NewJobReader(validationConfig JobConfig, receiver JobReceiver) *JobReader {
 	reader := new(JobReader)
 	reader.validationConfig = validationConfig
 	reader.receiver = receiver
@@ -32,6 +38,8 @@ func NewJobReader(validationConfig domain.JobValidationConfig, receiver JobRecei
 func (r JobReader) ReadFromDirectory(directory string) error {
 	logging.Info("Reading job configuration")
 
+	jobs := make([]*domain.Job, 0)
+
 	var walk filepath.WalkFunc = func(path string, file os.FileInfo, err error) error {
 		if file.IsDir() {
 			return nil
@@ -66,11 +74,13 @@ func (r JobReader) ReadFromDirectory(directory string) error {
 
 		logging.Debug("Read job from %s: %s\n", file.Name(), job)
 
+		job.ApplyOutputDefaults(r.validationConfig.MaxOutputBytes(), r.validationConfig.OutputDirectory())
+
 		if validErr := job.IsValid(r.validationConfig); validErr != nil {
 			return wrapError(validErr)
 		}
 
-		r.receiver.AddJob(job)
+		jobs = append(jobs, &job)
 
 		return nil
 	}
@@ -79,5 +89,13 @@ func (r JobReader) ReadFromDirectory(directory string) error {
 		return err
 	}
 
+	if err := resolveDependencies(jobs); err != nil {
+		return err
+	}
+
+	for _, job := range jobs {
+		r.receiver.AddJob(*job)
+	}
+
 	return nil
 }
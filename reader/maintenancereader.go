@@ -0,0 +1,78 @@
+package reader
+
+import (
+	"os"
+	"errors"
+	"fmt"
+	"strings"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"github.com/martin-helmich/distcrond/domain"
+	"github.com/martin-helmich/distcrond/logging"
+)
+
+type MaintenanceReader struct {
+	receiver MaintenanceReceiver
+}
+
+type MaintenanceReceiver interface {
+	AddWindow(*domain.MaintenanceWindow)
+}
+
+func NewMaintenanceReader(receiver MaintenanceReceiver) *MaintenanceReader {
+	reader := new(MaintenanceReader)
+	reader.receiver = receiver
+
+	return reader
+}
+
+func (r MaintenanceReader) ReadFromDirectory(directory string) error {
+	logging.Info("Reading maintenance windows")
+
+	var walk filepath.WalkFunc = func(path string, file os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if file.IsDir() {
+			return nil
+		}
+
+		if file.Name()[0] == '.' {
+			logging.Debug("Skipping %s", path)
+			return nil
+		}
+
+		fileContents, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		wrapError := func(err error) error {
+			return errors.New(fmt.Sprintf("Error parsing file %s: %s", path, err))
+		}
+
+		id := strings.Replace(file.Name(), ".json", "", 1)
+		windowJson := domain.MaintenanceWindowJson{}
+
+		if err = json.Unmarshal(fileContents, &windowJson); err != nil {
+			return wrapError(err)
+		}
+
+		window, err := domain.NewMaintenanceWindowFromJson(id, windowJson)
+		if err != nil {
+			return wrapError(err)
+		}
+
+		if validErr := window.IsValid(); validErr != nil {
+			return wrapError(validErr)
+		}
+
+		r.receiver.AddWindow(window)
+
+		return nil
+	}
+
+	return filepath.Walk(directory, walk)
+}
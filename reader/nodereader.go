@@ -14,15 +14,23 @@ import (
 )
 
 type NodeReader struct {
+	config NodeConfig
 	receiver NodeReceiver
 }
 
+type NodeConfig interface {
+	runner.SshConfig
+	RoleLimits() map[string]int
+	SshConfigFile() string
+}
+
 type NodeReceiver interface {
 	AddNode(domain.Node)
 }
 
-func NewNodeReader(receiver NodeReceiver) *NodeReader {
+func NewNodeReader(config NodeConfig, receiver NodeReceiver) *NodeReader {
 	reader := new(NodeReader)
+	reader.config = config
 	reader.receiver = receiver
 
 	return reader
@@ -31,6 +39,11 @@ func NewNodeReader(receiver NodeReceiver) *NodeReader {
 func (r NodeReader) ReadFromDirectory(directory string) error {
 	logging.Info("Reading node configuration")
 
+	sshConfig, err := r.readSshConfig()
+	if err != nil {
+		return err
+	}
+
 	var walk filepath.WalkFunc = func(path string, file os.FileInfo, err error) error {
 		if file.IsDir() {
 			return nil
@@ -62,11 +75,8 @@ func (r NodeReader) ReadFromDirectory(directory string) error {
 			return err
 		}
 
-		if str, strErr := runner.GetStrategyForNode(&node); strErr == nil {
-			node.ExecutionStrategy = str
-		} else {
-			return strErr
-		}
+		node.ApplyRoleLimits(r.config.RoleLimits())
+		node.ConnectionOptions.SetDefaults(node.ConnectionType, sshConfig)
 
 		logging.Debug("Read node from %s: %s", file.Name(), node)
 
@@ -74,6 +84,12 @@ func (r NodeReader) ReadFromDirectory(directory string) error {
 			return wrapError(validErr)
 		}
 
+		if str, strErr := runner.GetStrategyForNode(&node, r.config); strErr == nil {
+			node.ExecutionStrategy = str
+		} else {
+			return strErr
+		}
+
 		r.receiver.AddNode(node)
 
 		return nil
@@ -85,3 +101,25 @@ func (r NodeReader) ReadFromDirectory(directory string) error {
 
 	return nil
 }
+
+// Reads the OpenSSH client configuration, if one is configured.
+func (r NodeReader) readSshConfig() (*domain.SshClientConfig, error) {
+	filename := r.config.SshConfigFile()
+	if len(filename) == 0 {
+		return nil, nil
+	}
+
+	file, err := os.Open(domain.ExpandHome(filename))
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Could not read SSH configuration: %s", err))
+	}
+
+	defer file.Close()
+
+	sshConfig, err := domain.ParseSshClientConfig(file)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Could not parse SSH configuration %s: %s", filename, err))
+	}
+
+	return sshConfig, nil
+}
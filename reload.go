@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"github.com/martin-helmich/distcrond/container"
+	"github.com/martin-helmich/distcrond/domain"
+	"github.com/martin-helmich/distcrond/logging"
+	"github.com/martin-helmich/distcrond/reader"
+	"github.com/martin-helmich/distcrond/scheduler"
+	"github.com/martin-helmich/distcrond/storage"
+)
+
+// Re-reads the job and node configuration at runtime and applies all changes
+// without interrupting runs that are in progress.
+type Reloader struct {
+	config *RuntimeConfig
+	jobs *container.JobContainer
+	nodes *container.NodeContainer
+	maintenance *container.MaintenanceContainer
+	scheduler *scheduler.Scheduler
+	storage storage.StorageBackend
+
+	lock sync.Mutex
+}
+
+func NewReloader(config *RuntimeConfig, jobs *container.JobContainer, nodes *container.NodeContainer, maintenance *container.MaintenanceContainer, scheduler *scheduler.Scheduler, storage storage.StorageBackend) *Reloader {
+	return &Reloader{
+		config: config,
+		jobs: jobs,
+		nodes: nodes,
+		maintenance: maintenance,
+		scheduler: scheduler,
+		storage: storage,
+	}
+}
+
+func (r *Reloader) Reload() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	log := logging.Logger
+	log.Notice("Reloading configuration")
+
+	// Read everything first, so that invalid configuration is not applied
+	nodes := container.NewNodeContainer(r.nodes.Count())
+	if err := reader.NewNodeReader(r.config, nodes).ReadFromDirectory(r.config.NodesDirectory()); err != nil {
+		return err
+	}
+
+	jobs := container.NewJobContainer(r.jobs.Count())
+	if err := reader.NewJobReader(r.config, jobs).ReadFromDirectory(r.config.JobsDirectory()); err != nil {
+		return err
+	}
+
+	maintenance := container.NewMaintenanceContainer()
+	if r.config.MaintenanceDirectory() != "" {
+		if err := reader.NewMaintenanceReader(maintenance).ReadFromDirectory(r.config.MaintenanceDirectory()); err != nil {
+			return err
+		}
+		r.maintenance.Update(maintenance.All())
+	}
+
+	addedNodes, changedNodes, retiredNodes := r.nodes.Update(nodes.All())
+	log.Notice("Nodes: %d added, %d changed, %d removed", len(addedNodes), len(changedNodes), len(retiredNodes) - len(changedNodes))
+
+	for _, node := range retiredNodes {
+		go r.drain(node)
+	}
+
+	addedJobs, changedJobs, removedJobs := r.jobs.Update(jobs.All())
+	log.Notice("Jobs: %d added, %d changed, %d removed", len(addedJobs), len(changedJobs), len(removedJobs))
+
+	for _, name := range addedJobs {
+		if job, err := r.jobs.JobByName(name); err == nil {
+			r.restoreState(job)
+		}
+	}
+
+	r.scheduler.Reload()
+
+	return nil
+}
+
+func (r *Reloader) restoreState(job *domain.Job) {
+	if state, err := r.storage.LoadJobState(job); err != nil {
+		logging.Error("Could not load state of job %s: %s", job.Name, err)
+	} else {
+		job.RestoreState(state)
+	}
+}
+
+// Waits until a node that was removed from the configuration has completed all
+// of its jobs, and closes its connections. The node does not receive new jobs
+// in the meantime.
+func (r *Reloader) drain(node *domain.Node) {
+	if closer, ok := node.ExecutionStrategy.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	if atomic.LoadInt32(&node.RunningJobs) == 0 {
+		return
+	}
+
+	logging.Notice("Draining node %s", node.Name)
+
+	for atomic.LoadInt32(&node.RunningJobs) > 0 {
+		time.Sleep(time.Second)
+	}
+
+	logging.Notice("Node %s is drained", node.Name)
+}
+
+// Periodically checks the configuration directories for changes and reloads
+// the configuration when a change is detected.
+func (r *Reloader) Watch(interval time.Duration) {
+	last := r.fingerprint()
+
+	for range time.Tick(interval) {
+		current := r.fingerprint()
+		if current == last {
+			continue
+		}
+
+		last = current
+		if err := r.Reload(); err != nil {
+			logging.Error("Could not reload configuration: %s", err)
+		}
+	}
+}
+
+// Summarizes names, sizes and modification times of all configuration files.
+func (r *Reloader) fingerprint() string {
+	entries := make([]string, 0)
+
+	for _, directory := range []string{r.config.JobsDirectory(), r.config.NodesDirectory(), r.config.MaintenanceDirectory()} {
+		if directory == "" {
+			continue
+		}
+
+		filepath.Walk(directory, func(path string, file os.FileInfo, err error) error {
+			if err == nil && !file.IsDir() {
+				entries = append(entries, fmt.Sprintf("%s:%d:%d", path, file.Size(), file.ModTime().UnixNano()))
+			}
+			return nil
+		})
+	}
+
+	sort.Strings(entries)
+	return strings.Join(entries, "\n")
+}
@@ -7,7 +7,6 @@ import (
 	"errors"
 	"fmt"
 	"time"
-	"sync/atomic"
 )
 
 type AllJobRunner GenericJobRunner
@@ -16,7 +15,7 @@ func NewAllJobRunner(nodes *container.NodeContainer, storage storage.StorageBack
 	return &AllJobRunner{nodes: nodes, storage: storage, healthChecker: health}
 }
 
-func (r *AllJobRunner) Run(job *domain.Job) error {
+func (r *AllJobRunner) Run(job *domain.Job, report *domain.RunReport) error {
 	logger := job.Logger
 	nodes  := r.nodes.NodesForJob(job)
 
@@ -24,66 +23,94 @@ func (r *AllJobRunner) Run(job *domain.Job) error {
 		return errors.New(fmt.Sprintf("No nodes available for job %s", job.Name))
 	}
 
-	done := make(chan bool, len(nodes))
-	logger.Debug("Executing on %d nodes", len(nodes))
+	report.Initialize(job, len(nodes))
+
+	if job.Rolling != nil {
+		r.runRolling(job, report, nodes)
+	} else {
+		logger.Debug("Executing on %d nodes", len(nodes))
+		r.runBatch(job, nodes, report.Items)
+	}
 
-	job.Lock.Lock()
-	defer job.Lock.Unlock()
+	finishRun(job, report, r.storage)
 
-	report := domain.RunReport{}
-	report.Initialize(job, len(nodes))
+	logger.Info("%s: Done on all nodes", job.Name)
+
+	return nil
+}
+
+// Runs the job on the nodes in batches. The rollout is stopped when the
+// failure budget is used up or the run is cancelled.
+func (r *AllJobRunner) runRolling(job *domain.Job, report *domain.RunReport, nodes []*domain.Node) {
+	logger := job.Logger
+	policy := job.Rolling
+	failures := 0
+
+	logger.Debug("Rolling out on %d nodes in batches of %d", len(nodes), policy.BatchSize)
+
+	for start := 0; start < len(nodes); start += policy.BatchSize {
+		end := start + policy.BatchSize
+		if end > len(nodes) {
+			end = len(nodes)
+		}
+
+		if start > 0 && policy.Pause > 0 {
+			select {
+			case <-time.After(policy.Pause):
+			case <-report.Items[start].Abort:
+			}
+		}
+
+		if report.IsCancelled() {
+			r.skipRemaining(job, report, nodes, start, "Rollout was cancelled")
+			return
+		}
+
+		r.runBatch(job, nodes[start:end], report.Items[start:end])
+
+		for _, item := range report.Items[start:end] {
+			if !item.Success {
+				failures ++
+			}
+		}
+
+		if failures >= policy.MaxFailures && end < len(nodes) {
+			logger.Warning("Stopping rollout after %d failed nodes", failures)
+			r.skipRemaining(job, report, nodes, end, fmt.Sprintf("Rollout was stopped after %d failed nodes", failures))
+			return
+		}
+	}
+}
+
+func (r *AllJobRunner) skipRemaining(job *domain.Job, report *domain.RunReport, nodes []*domain.Node, from int, reason string) {
+	for i := from; i < len(nodes); i ++ {
+		report.Items[i].Skip(nodes[i], reason)
+	}
+}
+
+// Runs the job on the nodes in parallel, with one report item per node.
+func (r *AllJobRunner) runBatch(job *domain.Job, nodes []*domain.Node, items []domain.RunReportItem) {
+	logger := job.Logger
+	done := make(chan bool, len(nodes))
 
 	for i, node := range nodes {
 		go func(node *domain.Node, reportItem *domain.RunReportItem) {
-			logger.Debug("Executing on node %s\n", node.Name)
-
-			reportItem.Node = node
-			reportItem.Time.Start = time.Now()
-			atomic.AddInt32(&node.RunningJobs, 1)
-
-			strat := node.ExecutionStrategy
-			if err := strat.ExecuteCommand(job, reportItem); err != nil {
-				switch err.(type) {
-				case NodeDownError:
-					func() {
-						node.Lock.Lock();
-						defer node.Lock.Unlock()
-
-						logger.Warning("Node %s is down.", node.Name)
-						node.Status = domain.STATUS_DOWN
-					}()
-					r.healthChecker.ScheduleHealthCheck(node)
+			runWithRetries(job, reportItem, func(reportItem *domain.RunReportItem) error {
+				if _, err := acquireSlot(job, []*domain.Node{node}, reportItem); err != nil {
+					return err
 				}
+				defer node.ReleaseSlot()
 
-				logger.Error("%s", err)
-				reportItem.Success = false
-				reportItem.Output = err.Error()
-			}
-
-			atomic.AddInt32(&node.RunningJobs, -1)
-			reportItem.Time.Stop = time.Now()
+				return executeOnNode(job, node, reportItem, r.healthChecker)
+			})
 
-			logger.Debug("Done on %s\n", node.Name)
 			logger.Info("Report: %s\n", reportItem.Summary())
 
 			done <- true
-		}(node, &report.Items[i])
+		}(node, &items[i])
 	}
 
 	for i := 0; i < len(nodes); i ++ {
 		<- done
 	}
-
-	report.Finalize()
-	job.LastExecution = time.Now()
-
-	go func() {
-		if err := r.storage.SaveReport(&report); err != nil {
-			logger.Error("%s", err)
-		}
-	}()
-
-	logger.Info("%s: Done on all nodes", job.Name)
-
-	return nil
 }
@@ -7,7 +7,6 @@ import (
 	"github.com/martin-helmich/distcrond/domain"
 	"errors"
 	"time"
-	"sync/atomic"
 )
 
 type AnyJobRunner GenericJobRunner
@@ -16,7 +15,7 @@ func NewAnyJobRunner(nodes *container.NodeContainer, storage storage.StorageBack
 	return &AnyJobRunner{nodes: nodes, storage: storage, healthChecker: health}
 }
 
-func (r *AnyJobRunner) Run(job *domain.Job) error {
+func (r *AnyJobRunner) Run(job *domain.Job, report *domain.RunReport) error {
 	logger := job.Logger
 	nodes := r.nodes.NodeCandidatesForJob(job)
 
@@ -26,70 +25,69 @@ func (r *AnyJobRunner) Run(job *domain.Job) error {
 
 	logger.Debug("Executing on one of %d nodes", len(nodes))
 
-	job.Lock.Lock()
-	defer job.Lock.Unlock()
-
-	report := domain.RunReport{}
 	report.Initialize(job, 1)
 
 	reportItem := &report.Items[0]
-	reportItem.Time.Start = time.Now()
-	reportItem.Success = false
-	reportItem.Output = "Could not find any node to run job on"
-
-	for _, node := range nodes {
-		done := func() bool {
-			atomic.AddInt32(&node.RunningJobs, 1)
-			defer func() {
-				atomic.AddInt32(&node.RunningJobs, -1)
-				reportItem.Time.Stop = time.Now()
-			}()
-
-			logger.Debug("Executing on node %s\n", node.Name)
-			reportItem.Node = node
-
-			strat := node.ExecutionStrategy
-			if err := strat.ExecuteCommand(job, reportItem); err != nil {
-				switch err.(type) {
-				case NodeDownError:
-					func() {
-						node.Lock.Lock();
-						defer node.Lock.Unlock()
-
-						logger.Warning("Node %s is down.", node.Name)
-						node.Status = domain.STATUS_DOWN
-					}()
-					r.healthChecker.ScheduleHealthCheck(node)
-					return false
-
-				default:
-					logger.Error("%s", err)
-					reportItem.Success = false
-					reportItem.Output = err.Error()
-				}
+
+	runWithRetries(job, reportItem, func(reportItem *domain.RunReportItem) error {
+		reportItem.Time.Start = time.Now()
+		reportItem.Time.Stop = reportItem.Time.Start
+		reportItem.Success = false
+		reportItem.Output = "Could not find any node to run job on"
+
+		// Nodes might have changed their status since the last attempt. If
+		// all nodes are still considered down, try the previous ones again.
+		if reportItem.Attempt > 1 {
+			if candidates := r.nodes.NodeCandidatesForJob(job); len(candidates) > 0 {
+				nodes = candidates
+			}
+		}
+
+		// Saturated nodes are skipped (except for sticky jobs, which wait for
+		// their node); nodes that are down are left out for the remainder of
+		// this attempt.
+		remaining := nodes
+		var err error
+		for len(remaining) > 0 {
+			candidates := remaining
+			if job.Policy.Selection == domain.SELECTION_STICKY {
+				candidates = remaining[:1]
+			}
+
+			node, sErr := acquireSlot(job, candidates, reportItem)
+			if sErr != nil {
+				return sErr
 			}
 
-			logger.Debug("Done on %s\n", node.Name)
-			return true
-		}()
+			err = executeOnNode(job, node, reportItem, r.healthChecker)
+			node.ReleaseSlot()
 
-		if done {
-			break
+			if _, down := err.(NodeDownError); !down {
+				job.SetLastNode(node.Name)
+				return nil
+			}
+
+			remaining = withoutNode(remaining, node)
 		}
-	}
 
-	report.Finalize()
-	job.LastExecution = time.Now()
+		return err
+	})
 
 	logger.Info("Report: %s\n", reportItem.Summary())
 
-	go func() {
-		if err := r.storage.SaveReport(&report); err != nil {
-			logger.Error("%s", err)
-		}
-	}()
+	finishRun(job, report, r.storage)
 
 	logger.Info("%s: Done on all nodes", job.Name)
 
 	return nil
 }
+
+func withoutNode(nodes []*domain.Node, node *domain.Node) []*domain.Node {
+	result := make([]*domain.Node, 0, len(nodes))
+	for _, n := range nodes {
+		if n != node {
+			result = append(result, n)
+		}
+	}
+	return result
+}
@@ -4,8 +4,14 @@ import (
 	"errors"
 	. "github.com/martin-helmich/distcrond/domain"
 	"fmt"
+	"time"
 )
 
+// How long to wait for a killed command to terminate. Processes that escaped
+// the process group may keep the output pipes open after the command itself
+// was killed.
+var killGracePeriod = 5 * time.Second
+
 type NodeDownError struct {
 	realError error
 	reason string
@@ -20,13 +26,65 @@ func (e NodeDownError) Error() string {
 	return fmt.Sprintf("Node %s is down: %s (%s)", e.node.Name, e.reason, e.realError)
 }
 
-func GetStrategyForNode(node *Node) (ExecutionStrategy, error) {
+// The error that caused the node to be considered down.
+func (e NodeDownError) Cause() error {
+	return e.realError
+}
+
+// Waits for a started command to terminate. If the job's timeout expires or
+// the run is cancelled before that, the command is terminated using the "kill"
+// function and the report item is flagged accordingly. If the command still
+// does not terminate, waiting is given up on after a grace period.
+func waitForCommand(job *Job, report *RunReportItem, wait func() error, kill func()) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- wait()
+	}()
+
+	var timeout <-chan time.Time
+	if job.Timeout > 0 {
+		timer := time.NewTimer(job.Timeout)
+		defer timer.Stop()
+
+		timeout = timer.C
+	}
+
+	select {
+	case err := <-done:
+		return err
+	case <-timeout:
+		job.Logger.Warning("Command did not complete within %s, killing it", job.Timeout)
+		report.TimedOut = true
+	case <-report.Abort:
+		job.Logger.Warning("Run was cancelled, killing command")
+		report.Cancelled = true
+	}
+
+	kill()
+
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(killGracePeriod):
+		job.Logger.Error("Command did not terminate within %s after being killed, abandoning it", killGracePeriod)
+		return errors.New(fmt.Sprintf("Command did not terminate within %s after being killed", killGracePeriod))
+	}
+}
+
+func GetStrategyForNode(node *Node, config SshConfig) (ExecutionStrategy, error) {
 	switch {
 	case node.ConnectionType == CONN_LOCAL:
 		return &LocalExecutionStrategy{node}, nil
 
 	case node.ConnectionType == CONN_SSH:
-		if str, err := NewSshExecutionStrategy(node); err != nil {
+		if str, err := NewSshExecutionStrategy(node, config); err != nil {
+			return nil, err
+		} else {
+			return str, nil
+		}
+
+	case node.ConnectionType == CONN_HTTP:
+		if str, err := NewHttpExecutionStrategy(node); err != nil {
 			return nil, err
 		} else {
 			return str, nil
@@ -0,0 +1,59 @@
+package runner
+
+import (
+	"testing"
+	"time"
+	"github.com/martin-helmich/distcrond/domain"
+	"github.com/op/go-logging"
+)
+
+func TestWaitForCommandGivesUpOnCommandsThatIgnoreKill(t *testing.T) {
+	defer func(grace time.Duration) { killGracePeriod = grace }(killGracePeriod)
+	killGracePeriod = 50 * time.Millisecond
+
+	job := &domain.Job{Name: "stuck", Timeout: 10 * time.Millisecond, Logger: logging.MustGetLogger("test")}
+	report := &domain.RunReportItem{}
+
+	hang := make(chan struct{})
+	defer close(hang)
+
+	killed := false
+	result := make(chan error, 1)
+	go func() {
+		result <- waitForCommand(job, report, func() error {
+			<-hang
+			return nil
+		}, func() {
+			killed = true
+		})
+	}()
+
+	select {
+	case err := <-result:
+		if err == nil {
+			t.Error("Expected an error for a command that did not terminate")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("waitForCommand did not return")
+	}
+
+	if !killed || !report.TimedOut {
+		t.Errorf("Expected the command to be killed and flagged as timed out (killed: %v, timed out: %v)", killed, report.TimedOut)
+	}
+}
+
+func TestOutputCaptureRejectsWritesAfterApply(t *testing.T) {
+	capture := newOutputCapture(&domain.Job{}, &domain.RunReportItem{})
+	capture.Stdout().Write([]byte("before"))
+
+	report := domain.RunReportItem{}
+	capture.apply(&report)
+
+	if _, err := capture.Stdout().Write([]byte("after")); err == nil {
+		t.Error("Expected writes after apply to fail")
+	}
+
+	if report.Output != "before" {
+		t.Errorf("Unexpected output %q", report.Output)
+	}
+}
@@ -0,0 +1,257 @@
+package runner
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+	. "github.com/martin-helmich/distcrond/domain"
+)
+
+// Timeout for connecting to HTTP nodes in health checks
+const httpHealthCheckTimeout = 10 * time.Second
+
+// Maximum size of responses that are checked with a success path
+const maxSuccessDocumentBytes = 10 << 20
+
+// Performs an HTTP request instead of running a command. The URL and the body
+// are templates (see RequestTemplateData); the response body is stored as the
+// command's output.
+type HttpExecutionStrategy struct {
+	node *Node
+	url *template.Template
+	body *template.Template
+	successPath *JsonPath
+	healthUrl string
+	client *http.Client
+}
+
+func NewHttpExecutionStrategy(node *Node) (*HttpExecutionStrategy, error) {
+	options := node.ConnectionOptions
+	strat := &HttpExecutionStrategy{node: node, client: &http.Client{}}
+
+	var err error
+	if strat.url, err = ParseRequestTemplate(options.HttpUrl); err != nil {
+		return nil, errors.New(fmt.Sprintf("Invalid URL template for node %s: %s", node.Name, err))
+	}
+
+	if strat.body, err = ParseRequestTemplate(options.HttpBody); err != nil {
+		return nil, errors.New(fmt.Sprintf("Invalid body template for node %s: %s", node.Name, err))
+	}
+
+	if len(options.HttpSuccessPath) > 0 {
+		path, err := ParseJsonPath(options.HttpSuccessPath)
+		if err != nil {
+			return nil, err
+		}
+		strat.successPath = &path
+	}
+
+	// Without a separate health check URL, the URL template is rendered
+	// without any values for the health check
+	strat.healthUrl = options.HttpHealthUrl
+	if len(strat.healthUrl) == 0 {
+		healthTemplate, err := strat.url.Clone()
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Invalid URL template for node %s: %s", node.Name, err))
+		}
+
+		var buffer bytes.Buffer
+		if err := healthTemplate.Option("missingkey=zero").Execute(&buffer, RequestTemplateData{}); err != nil {
+			return nil, errors.New(fmt.Sprintf("Could not render health check URL for node %s, set http_health_url: %s", node.Name, err))
+		}
+		strat.healthUrl = buffer.String()
+	}
+
+	return strat, nil
+}
+
+// Checks if the server is available: by requesting the health check URL, if
+// there is one, and by connecting to the server otherwise.
+func (s *HttpExecutionStrategy) HealthCheck() error {
+	target, err := url.Parse(s.healthUrl)
+	if err != nil {
+		return NewNodeDownError(s.node, "Invalid health check URL", err)
+	}
+
+	if len(s.node.ConnectionOptions.HttpHealthUrl) > 0 {
+		client := &http.Client{Timeout: httpHealthCheckTimeout}
+		resp, err := client.Get(target.String())
+		if err != nil {
+			return NewNodeDownError(s.node, "Health check request failed", err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode >= 500 {
+			return NewNodeDownError(s.node, "Health check request failed", errors.New(resp.Status))
+		}
+		return nil
+	}
+
+	address := target.Host
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		if target.Scheme == "https" {
+			address = net.JoinHostPort(address, "443")
+		} else {
+			address = net.JoinHostPort(address, "80")
+		}
+	}
+
+	conn, err := net.DialTimeout("tcp", address, httpHealthCheckTimeout)
+	if err != nil {
+		return NewNodeDownError(s.node, "Could not connect", err)
+	}
+	conn.Close()
+
+	return nil
+}
+
+func (s *HttpExecutionStrategy) ExecuteCommand(job *Job, report *RunReportItem) error {
+	options := s.node.ConnectionOptions
+	data := NewRequestTemplateData(job, report)
+
+	var rawUrl, body bytes.Buffer
+	if err := s.url.Execute(&rawUrl, data); err != nil {
+		report.Output = fmt.Sprintf("Could not build request URL: %s", err)
+		report.Success = false
+		return nil
+	}
+
+	if err := s.body.Execute(&body, data); err != nil {
+		report.Output = fmt.Sprintf("Could not build request body: %s", err)
+		report.Success = false
+		return nil
+	}
+
+	req, err := http.NewRequest(options.HttpMethod, rawUrl.String(), &body)
+	if err != nil {
+		report.Output = fmt.Sprintf("Could not build request: %s", err)
+		report.Success = false
+		return nil
+	}
+
+	for key, value := range options.HttpHeaders {
+		// The Host header is taken from the request, not from the headers
+		if strings.EqualFold(key, "Host") {
+			req.Host = value
+		} else {
+			req.Header.Set(key, value)
+		}
+	}
+
+	cancel := make(chan struct{})
+	req.Cancel = cancel
+
+	job.Logger.Debug("Sending %s request to %s", req.Method, req.URL)
+
+	output := newOutputCapture(job, report)
+
+	// The request may still be running after it was given up on, so its
+	// result is handed over through a channel
+	results := make(chan httpResult, 1)
+	runErr := waitForCommand(job, report, func() error {
+		var result httpResult
+		defer func() { results <- result }()
+
+		resp, err := s.client.Do(req)
+		if err != nil {
+			result.connectErr = err
+			return err
+		}
+
+		defer resp.Body.Close()
+
+		result.status = resp.StatusCode
+		stdout := output.Stdout()
+
+		// Keep the body for the success check; the output may be truncated
+		if s.successPath != nil {
+			result.document, err = ioutil.ReadAll(io.LimitReader(resp.Body, maxSuccessDocumentBytes + 1))
+			stdout.Write(result.document)
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err = io.Copy(stdout, resp.Body)
+		return err
+	}, func() {
+		close(cancel)
+	})
+
+	var result httpResult
+	select {
+	case result = <-results:
+	default:
+	}
+
+	output.apply(report)
+	report.StatusCode = result.status
+
+	if result.connectErr != nil && !report.TimedOut && !report.Cancelled {
+		return NewNodeDownError(s.node, "Request failed", result.connectErr)
+	}
+
+	report.Success = runErr == nil && !report.TimedOut && !report.Cancelled && options.IsHttpSuccessStatus(report.StatusCode)
+
+	if report.Success && s.successPath != nil {
+		if len(result.document) > maxSuccessDocumentBytes {
+			report.Stderr = fmt.Sprintf("Response exceeds %d bytes and cannot be checked", maxSuccessDocumentBytes)
+			report.Success = false
+		} else if err := s.checkResponse(result.document); err != nil {
+			job.Logger.Warning("Request to %s was not successful: %s", req.URL, err)
+			report.Stderr = err.Error()
+			report.Success = false
+		}
+	}
+
+	return nil
+}
+
+type httpResult struct {
+	status int
+	connectErr error
+	document []byte
+}
+
+// Checks the value that the success path selects from the response. Without
+// an expected value, any value except null and false indicates success.
+func (s *HttpExecutionStrategy) checkResponse(body []byte) error {
+	var document interface{}
+	if err := json.Unmarshal(body, &document); err != nil {
+		return errors.New(fmt.Sprintf("Response is not valid JSON: %s", err))
+	}
+
+	value, ok := s.successPath.Lookup(document)
+	if !ok {
+		return errors.New(fmt.Sprintf("Response does not contain %s", s.successPath))
+	}
+
+	expected := s.node.ConnectionOptions.HttpSuccessValue
+	if expected == nil {
+		if value == nil || value == false {
+			return errors.New(fmt.Sprintf("%s is %v", s.successPath, value))
+		}
+		return nil
+	}
+
+	actual, isString := value.(string)
+	if !isString {
+		encoded, _ := json.Marshal(value)
+		actual = string(encoded)
+	}
+
+	if actual != *expected {
+		return errors.New(fmt.Sprintf("%s is %s, expected %s", s.successPath, actual, *expected))
+	}
+
+	return nil
+}
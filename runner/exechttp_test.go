@@ -0,0 +1,114 @@
+package runner
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"github.com/martin-helmich/distcrond/domain"
+	logging "github.com/op/go-logging"
+)
+
+func executeHttpRequest(t *testing.T, status int, body string, options domain.ConnectionOptions) *domain.RunReportItem {
+	server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/hooks/backup" || req.Method != "POST" {
+			resp.WriteHeader(404)
+			return
+		}
+		resp.WriteHeader(status)
+		resp.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	options.HttpUrl = server.URL + "/hooks/{{.Job}}"
+	options.HttpBody = "{}"
+
+	node := &domain.Node{Name: "hooks", ConnectionType: domain.CONN_HTTP, ConnectionOptions: options}
+	node.ConnectionOptions.SetDefaults(node.ConnectionType, nil)
+
+	strat, err := NewHttpExecutionStrategy(node)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	job := &domain.Job{Name: "backup", Command: domain.ExecCommand{}, Logger: logging.MustGetLogger("test")}
+	report := &domain.RunReportItem{}
+
+	if err := strat.ExecuteCommand(job, report); err != nil {
+		t.Fatal(err)
+	}
+
+	if report.StatusCode != status {
+		t.Errorf("Expected status %d, got %d", status, report.StatusCode)
+	}
+
+	if report.Output != body {
+		t.Errorf("Unexpected output %q", report.Output)
+	}
+
+	return report
+}
+
+func TestHttpExecutionStrategyChecksResponse(t *testing.T) {
+	expected := "ok"
+	options := domain.ConnectionOptions{HttpSuccessPath: "$.result.status", HttpSuccessValue: &expected}
+
+	if report := executeHttpRequest(t, 200, `{"result": {"status": "failed"}}`, options); report.Success {
+		t.Error("Expected request to fail because the value at $.result.status does not match")
+	}
+
+	if report := executeHttpRequest(t, 200, `{"result": {"status": "ok"}}`, options); !report.Success {
+		t.Error("Expected request to succeed because the value at $.result.status matches")
+	}
+}
+
+func TestHttpExecutionStrategyChecksStatus(t *testing.T) {
+	if report := executeHttpRequest(t, 500, "error", domain.ConnectionOptions{}); report.Success {
+		t.Error("Expected request to fail because of the response status")
+	}
+
+	if report := executeHttpRequest(t, 202, "accepted", domain.ConnectionOptions{}); !report.Success {
+		t.Error("Expected request with a 2xx status to succeed")
+	}
+
+	options := domain.ConnectionOptions{HttpSuccessStatus: []int{200}}
+	if report := executeHttpRequest(t, 202, "accepted", options); report.Success {
+		t.Error("Expected request to fail because the status is not listed in http_success_status")
+	}
+
+	options = domain.ConnectionOptions{HttpSuccessStatus: []int{200, 409}}
+	if report := executeHttpRequest(t, 409, "conflict", options); !report.Success {
+		t.Error("Expected request to succeed because the status is listed in http_success_status")
+	}
+}
+
+func TestHttpExecutionStrategySetsHostHeader(t *testing.T) {
+	var host string
+	server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		host = req.Host
+	}))
+	defer server.Close()
+
+	node := &domain.Node{Name: "hooks", ConnectionType: domain.CONN_HTTP, ConnectionOptions: domain.ConnectionOptions{
+		HttpUrl: server.URL + "/hooks/{{.Job}}",
+		HttpHeaders: map[string]string{"host": "hooks.example.com"},
+	}}
+	node.ConnectionOptions.SetDefaults(node.ConnectionType, nil)
+
+	strat, err := NewHttpExecutionStrategy(node)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	job := &domain.Job{Name: "backup", Command: domain.ExecCommand{}, Logger: logging.MustGetLogger("test")}
+	if err := strat.ExecuteCommand(job, &domain.RunReportItem{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if host != "hooks.example.com" {
+		t.Errorf("Expected Host header hooks.example.com, got %s", host)
+	}
+
+	if strat.healthUrl != server.URL + "/hooks/" {
+		t.Errorf("Unexpected health check URL %s", strat.healthUrl)
+	}
+}
@@ -3,9 +3,27 @@ package runner
 import (
 	"os/exec"
 	"github.com/martin-helmich/distcrond/domain"
-	"bytes"
+	"fmt"
+	"syscall"
 )
 
+var signalNames = map[syscall.Signal]string{
+	syscall.SIGABRT: "SIGABRT",
+	syscall.SIGALRM: "SIGALRM",
+	syscall.SIGBUS: "SIGBUS",
+	syscall.SIGFPE: "SIGFPE",
+	syscall.SIGHUP: "SIGHUP",
+	syscall.SIGILL: "SIGILL",
+	syscall.SIGINT: "SIGINT",
+	syscall.SIGKILL: "SIGKILL",
+	syscall.SIGPIPE: "SIGPIPE",
+	syscall.SIGQUIT: "SIGQUIT",
+	syscall.SIGSEGV: "SIGSEGV",
+	syscall.SIGTERM: "SIGTERM",
+	syscall.SIGUSR1: "SIGUSR1",
+	syscall.SIGUSR2: "SIGUSR2",
+}
+
 type LocalExecutionStrategy struct {
 	node *domain.Node
 }
@@ -15,7 +33,6 @@ func (s *LocalExecutionStrategy) HealthCheck() error {
 }
 
 func (s *LocalExecutionStrategy) ExecuteCommand(job *domain.Job, report *domain.RunReportItem) error {
-	var output bytes.Buffer
 	var cmd *exec.Cmd
 
 	args := job.Command.Command()
@@ -34,13 +51,29 @@ func (s *LocalExecutionStrategy) ExecuteCommand(job *domain.Job, report *domain.
 		Args: args,
 		Env: env,
 	}
-	cmd.Stdout = &output
 
-	err := cmd.Run()
+	output := newOutputCapture(job, report)
+	cmd.Stdout = output.Stdout()
+	cmd.Stderr = output.Stderr()
 
-	report.Output = output.String()
+	// Run the command in its own process group, so that the entire process
+	// tree can be killed when the job times out.
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
 
-	if err == nil {
+	if err := cmd.Start(); err != nil {
+		report.Output = err.Error()
+		report.Success = false
+		return nil
+	}
+
+	err := waitForCommand(job, report, cmd.Wait, func() {
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	})
+
+	output.apply(report)
+	setLocalExitStatus(report, err)
+
+	if err == nil && !report.TimedOut && !report.Cancelled {
 		report.Success = true
 	} else {
 		report.Success = false
@@ -48,3 +81,29 @@ func (s *LocalExecutionStrategy) ExecuteCommand(job *domain.Job, report *domain.
 
 	return nil
 }
+
+// Records the exit code (or the signal) of a command that has terminated.
+func setLocalExitStatus(report *domain.RunReportItem, err error) {
+	if err == nil {
+		report.SetExitCode(0)
+		return
+	}
+
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok {
+		return
+	}
+
+	status, ok := exitErr.Sys().(syscall.WaitStatus)
+	switch {
+	case !ok:
+	case status.Signaled():
+		if name, known := signalNames[status.Signal()]; known {
+			report.SetSignal(name)
+		} else {
+			report.SetSignal(fmt.Sprintf("signal %d", status.Signal()))
+		}
+	case status.Exited():
+		report.SetExitCode(status.ExitStatus())
+	}
+}
@@ -2,9 +2,7 @@ package runner
 
 import (
 	"golang.org/x/crypto/ssh"
-	"io/ioutil"
 	. "github.com/martin-helmich/distcrond/domain"
-	"bytes"
 	"errors"
 	"fmt"
 	"strings"
@@ -12,69 +10,94 @@ import (
 
 type SshExecutionStrategy struct {
 	node *Node
-	privateKey ssh.Signer
-	clientConfig ssh.ClientConfig
+	target *sshEndpoint
+	jump *jumpHost
+	pool *sshPool
 }
 
-func NewSshExecutionStrategy (node *Node) (*SshExecutionStrategy, error) {
+func NewSshExecutionStrategy (node *Node, config SshConfig) (*SshExecutionStrategy, error) {
 	strat := new(SshExecutionStrategy)
 
-	keyString, keyErr := ioutil.ReadFile(node.ConnectionOptions.SshKeyFile)
-	if keyErr != nil {
-		return nil, errors.New(fmt.Sprintf("Could not read private key file %s: %s", node.ConnectionOptions.SshKeyFile, keyErr))
+	target, err := newSshEndpoint(node.ConnectionOptions, config)
+	if err != nil {
+		return nil, err
 	}
 
-	privateKey, keyParseError := ssh.ParsePrivateKey(keyString)
-	if keyParseError != nil {
-		return nil, errors.New(fmt.Sprintf("Could not parse private key file %s: %s", node.ConnectionOptions.SshKeyFile, keyParseError))
+	strat.node   = node
+	strat.target = target
+
+	for i, options := range node.ConnectionOptions.SshJumpHosts {
+		if strat.jump, err = newJumpHost(options, config, strat.jump); err != nil {
+			return nil, errors.New(fmt.Sprintf("Jump host %d: %s", i + 1, err))
+		}
 	}
 
-	strat.node         = node
-	strat.privateKey   = privateKey
-	strat.clientConfig = ssh.ClientConfig{
-		User: node.ConnectionOptions.SshUser,
-		Auth: []ssh.AuthMethod{ssh.PublicKeys(privateKey)},
+	keepAlive, err := node.ConnectionOptions.SshKeepAliveInterval()
+	if err != nil {
+		return nil, err
 	}
 
+	strat.pool = newSshPool(node.Name, strat.dial, node.ConnectionOptions.SshSessionLimit(), keepAlive)
+
 	return strat, nil
 }
 
+// Connects to the node, through the jump hosts if there are any.
+func (s *SshExecutionStrategy) dial() (*ssh.Client, error) {
+	if s.jump == nil {
+		return s.target.connect(nil)
+	}
+
+	jump, err := acquireJumpConnection(s.jump)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := s.target.connect(jump.client)
+	if err != nil {
+		jump.release()
+		return nil, err
+	}
+
+	go func() {
+		client.Wait()
+		jump.release()
+	}()
+
+	return client, nil
+}
+
+// Closes all connections to the node.
+func (s *SshExecutionStrategy) Close() error {
+	return s.pool.Close()
+}
+
 func (s *SshExecutionStrategy) quote(c string) string {
 	return "'" + strings.Replace(c, "'", "\\'", -1) + "'"
 }
 
 func (s *SshExecutionStrategy) HealthCheck() error {
-	client, clientErr := ssh.Dial("tcp", s.node.ConnectionOptions.SshHost, &s.clientConfig)
-	if clientErr != nil {
-		return NewNodeDownError(s.node, "Could not open TCP connection", clientErr)
+	_, release, err := s.pool.Session()
+	if err != nil {
+		return NewNodeDownError(s.node, "Could not connect", err)
 	}
 
-	session, sesErr := client.NewSession()
-	if sesErr != nil {
-		return NewNodeDownError(s.node, "Could not start SSH session", sesErr)
-	}
-
-	defer session.Close()
+	release()
 
 	return nil
 }
 
 func (s *SshExecutionStrategy) ExecuteCommand(job *Job, report *RunReportItem) error {
-	var output bytes.Buffer
-
-	client, clientErr := ssh.Dial("tcp", s.node.ConnectionOptions.SshHost, &s.clientConfig)
-	if clientErr != nil {
-		return NewNodeDownError(s.node, "Could not open TCP connection", clientErr)
+	session, release, err := s.pool.Session()
+	if err != nil {
+		return NewNodeDownError(s.node, "Could not connect", err)
 	}
 
-	session, sesErr := client.NewSession()
-	if sesErr != nil {
-		return NewNodeDownError(s.node, "Could not start SSH session", sesErr)
-	}
+	defer release()
 
-	defer session.Close()
-
-	session.Stdout = &output
+	output := newOutputCapture(job, report)
+	session.Stdout = output.Stdout()
+	session.Stderr = output.Stderr()
 
 	originalArgs := job.Command.Command()
 	quotedArgs := make([]string, len(originalArgs))
@@ -97,11 +120,23 @@ func (s *SshExecutionStrategy) ExecuteCommand(job *Job, report *RunReportItem) e
 
 	job.Logger.Debug("Actually running \"%s\"", cmd)
 
-	runErr := session.Run(strings.Join(cmdStrings, " ; "))
+	if startErr := session.Start(cmd); startErr != nil {
+		report.Output = startErr.Error()
+		report.Success = false
+		return nil
+	}
+
+	// The connection is shared with other commands and must stay open. If the
+	// node does not respond anymore, the keepalives close the connection.
+	runErr := waitForCommand(job, report, session.Wait, func() {
+		session.Signal(ssh.SIGKILL)
+		session.Close()
+	})
 
-	report.Output = output.String()
+	output.apply(report)
+	setSshExitStatus(report, runErr)
 
-	if runErr == nil {
+	if runErr == nil && !report.TimedOut && !report.Cancelled {
 		report.Success = true
 	} else {
 		report.Success = false
@@ -109,3 +144,20 @@ func (s *SshExecutionStrategy) ExecuteCommand(job *Job, report *RunReportItem) e
 
 	return nil
 }
+
+// Records the exit code (or the signal) of a remote command that has
+// terminated. Servers report signal names without the "SIG" prefix.
+func setSshExitStatus(report *RunReportItem, err error) {
+	if err == nil {
+		report.SetExitCode(0)
+		return
+	}
+
+	if exitErr, ok := err.(*ssh.ExitError); ok {
+		if signal := exitErr.Signal(); len(signal) > 0 {
+			report.SetSignal("SIG" + signal)
+		} else {
+			report.SetExitCode(exitErr.ExitStatus())
+		}
+	}
+}
@@ -42,9 +42,16 @@ func (h *healthCheckerImpl) ScheduleHealthCheck(node *domain.Node) {
 						defer node.Lock.Unlock()
 
 						node.Status = domain.STATUS_UP
+						node.DownError = nil
 					}()
 				} else {
-					h.logger.Warning("Node %s is still down", node.Name)
+					h.logger.Warning("Node %s is still down: %s", node.Name, err)
+
+					if down, ok := err.(NodeDownError); ok {
+						node.Lock.Lock()
+						node.DownError = down.Cause()
+						node.Lock.Unlock()
+					}
 				}
 			}
 		}
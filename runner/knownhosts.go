@@ -0,0 +1,218 @@
+package runner
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"golang.org/x/crypto/ssh"
+	"github.com/martin-helmich/distcrond/domain"
+)
+
+// Guards the host key store, which is shared by all nodes.
+var hostKeyStoreLock sync.Mutex
+
+type SshConfig interface {
+	KnownHostsFile() string
+	HostKeyStore() string
+}
+
+// Verifies the host keys of a node. A key pinned in the node's configuration
+// takes precedence; otherwise, the key must be listed in the known_hosts file
+// or in the host key store. When a host key store is configured, the keys of
+// unknown hosts are recorded there on the first connection (trust on first
+// use).
+type hostKeyVerifier struct {
+	host string
+	pin string
+	knownHostsFile string
+	store string
+}
+
+func newHostKeyVerifier(options domain.ConnectionOptions, config SshConfig) *hostKeyVerifier {
+	return &hostKeyVerifier{
+		host: options.SshHost,
+		pin: options.SshHostKey,
+		knownHostsFile: config.KnownHostsFile(),
+		store: config.HostKeyStore(),
+	}
+}
+
+func (v *hostKeyVerifier) Verify(key ssh.PublicKey) error {
+	fingerprint := domain.FingerprintSHA256(key)
+
+	if len(v.pin) > 0 {
+		if !domain.FingerprintMatches(key, v.pin) {
+			return &domain.HostKeyMismatchError{Host: v.host, Fingerprint: fingerprint, Expected: []string{v.pin}}
+		}
+		return nil
+	}
+
+	hostKeyStoreLock.Lock()
+	defer hostKeyStoreLock.Unlock()
+
+	// Only keys of the negotiated type can be compared; a host that is only
+	// known with keys of other types is treated as unknown
+	var known []ssh.PublicKey
+	for _, file := range []string{v.knownHostsFile, v.store} {
+		keys, err := readKnownHosts(file, v.host)
+		if err != nil {
+			return err
+		}
+
+		for _, k := range keys {
+			if k.Type() == key.Type() {
+				known = append(known, k)
+			}
+		}
+	}
+
+	for _, k := range known {
+		if bytes.Equal(k.Marshal(), key.Marshal()) {
+			return nil
+		}
+	}
+
+	if len(known) > 0 {
+		expected := make([]string, len(known))
+		for i, k := range known {
+			expected[i] = domain.FingerprintSHA256(k)
+		}
+		return &domain.HostKeyMismatchError{Host: v.host, Fingerprint: fingerprint, Expected: expected}
+	}
+
+	if len(v.store) == 0 {
+		return errors.New(fmt.Sprintf("Host key of %s is unknown (%s)", v.host, fingerprint))
+	}
+
+	return v.record(key)
+}
+
+// Adds a host key to the host key store.
+func (v *hostKeyVerifier) record(key ssh.PublicKey) error {
+	file, err := os.OpenFile(v.store, os.O_WRONLY | os.O_APPEND | os.O_CREATE, 0600)
+	if err != nil {
+		return errors.New(fmt.Sprintf("Could not record host key of %s: %s", v.host, err))
+	}
+
+	defer file.Close()
+
+	if _, err := file.Write(append([]byte(knownHostsName(v.host) + " "), ssh.MarshalAuthorizedKey(key)...)); err != nil {
+		return errors.New(fmt.Sprintf("Could not record host key of %s: %s", v.host, err))
+	}
+
+	return nil
+}
+
+// Reads the keys of a host from a file in OpenSSH's known_hosts format.
+// Missing files are treated like empty ones. Revoked keys, certificate
+// authorities and keys that cannot be parsed (like key types that are not
+// supported) are ignored.
+func readKnownHosts(filename string, host string) ([]ssh.PublicKey, error) {
+	if len(filename) == 0 {
+		return nil, nil
+	}
+
+	file, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	name := knownHostsName(host)
+	keys := make([]ssh.PublicKey, 0)
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || line[0] == '#' || line[0] == '@' {
+			continue
+		}
+
+		separator := strings.IndexAny(line, " \t")
+		if separator < 0 || !knownHostsMatch(line[:separator], name) {
+			continue
+		}
+
+		key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(line[separator + 1:]))
+		if err != nil {
+			continue
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys, scanner.Err()
+}
+
+// Returns the name under which a host is listed in known_hosts files: just the
+// host for the default port, and "[host]:port" otherwise.
+func knownHostsName(address string) string {
+	host, port, err := net.SplitHostPort(strings.ToLower(address))
+	if err != nil {
+		return strings.ToLower(address)
+	}
+
+	if port == "22" {
+		return host
+	}
+
+	return "[" + host + "]:" + port
+}
+
+// Checks if a host matches the host patterns of a known_hosts line. Patterns
+// may be hashed, contain wildcards, or be negated.
+func knownHostsMatch(patterns string, name string) bool {
+	if strings.HasPrefix(patterns, "|1|") {
+		return hashedHostMatch(patterns, name)
+	}
+
+	matched := false
+	for _, pattern := range strings.Split(patterns, ",") {
+		negated := strings.HasPrefix(pattern, "!")
+		if negated {
+			pattern = pattern[1:]
+		}
+
+		if domain.WildcardMatch(pattern, name) {
+			if negated {
+				return false
+			}
+			matched = true
+		}
+	}
+
+	return matched
+}
+
+func hashedHostMatch(entry string, name string) bool {
+	parts := strings.Split(entry[3:], "|")
+	if len(parts) != 2 {
+		return false
+	}
+
+	salt, err := base64.StdEncoding.DecodeString(parts[0])
+	if err != nil {
+		return false
+	}
+
+	expected, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha1.New, salt)
+	mac.Write([]byte(name))
+
+	return hmac.Equal(mac.Sum(nil), expected)
+}
@@ -0,0 +1,83 @@
+package runner
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"golang.org/x/crypto/ssh"
+	"github.com/martin-helmich/distcrond/domain"
+)
+
+func TestKnownHostsNameIncludesNonDefaultPort(t *testing.T) {
+	if name := knownHostsName("Example.COM:22"); name != "example.com" {
+		t.Errorf("Unexpected name %s", name)
+	}
+
+	if name := knownHostsName("example.com:2222"); name != "[example.com]:2222" {
+		t.Errorf("Unexpected name %s", name)
+	}
+}
+
+func TestKnownHostsMatchesPatterns(t *testing.T) {
+	cases := []struct {
+		patterns string
+		name string
+		match bool
+	}{
+		{"example.com,10.0.0.1", "example.com", true},
+		{"*.example.com", "web1.example.com", true},
+		{"*.example.com,!db.example.com", "db.example.com", false},
+		{"web?.example.com", "web12.example.com", false},
+		{"[example.com]:2222", "[example.com]:2222", true},
+		{"|1|MDEyMzQ1Njc4OWFiY2RlZmdoaWo=|jaHXoMQTU/+rEgquOJTQzPGCF4I=", "example.com", true},
+		{"|1|MDEyMzQ1Njc4OWFiY2RlZmdoaWo=|jaHXoMQTU/+rEgquOJTQzPGCF4I=", "example.org", false},
+	}
+
+	for _, c := range cases {
+		if match := knownHostsMatch(c.patterns, c.name); match != c.match {
+			t.Errorf("Expected match of %s against %s to be %t", c.name, c.patterns, c.match)
+		}
+	}
+}
+
+func TestHostKeyVerifierComparesOnlyKeysOfSameType(t *testing.T) {
+	directory, err := ioutil.TempDir("", "knownhosts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(directory)
+
+	ecdsaKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	knownKey, _ := ssh.NewPublicKey(&ecdsaKey.PublicKey)
+
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 1024)
+	presentedKey, _ := ssh.NewPublicKey(&rsaKey.PublicKey)
+
+	otherRsaKey, _ := rsa.GenerateKey(rand.Reader, 1024)
+	otherKey, _ := ssh.NewPublicKey(&otherRsaKey.PublicKey)
+
+	knownHosts := filepath.Join(directory, "known_hosts")
+	contents := "example.com ssh-unknown AAAAC3NzaC1lZDI1NTE5AAAAIA==\n" + "example.com " + string(ssh.MarshalAuthorizedKey(knownKey))
+	if err := ioutil.WriteFile(knownHosts, []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	verifier := &hostKeyVerifier{host: "example.com:22", knownHostsFile: knownHosts, store: filepath.Join(directory, "store")}
+
+	if err := verifier.Verify(presentedKey); err != nil {
+		t.Fatalf("Expected key of a new type to be recorded, got %s", err)
+	}
+
+	if err := verifier.Verify(presentedKey); err != nil {
+		t.Errorf("Expected recorded key to be accepted, got %s", err)
+	}
+
+	if _, ok := verifier.Verify(otherKey).(*domain.HostKeyMismatchError); !ok {
+		t.Error("Expected a different key of a known type to be a mismatch")
+	}
+}
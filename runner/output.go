@@ -0,0 +1,302 @@
+package runner
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sync"
+	"time"
+	"github.com/martin-helmich/distcrond/domain"
+)
+
+// Captures the output streams of a command, and passes them on to the run's
+// live output stream. When the job asks for combined
+// output, the lines of both streams are also recorded in the order in which
+// they were written, each with the time it was written at.
+//
+// When the job has an output limit, only the beginning and the end of each
+// stream are kept in memory. Jobs that spool their output write the complete
+// streams to files in the output directory instead.
+type outputCapture struct {
+	stdout *boundedBuffer
+	stderr *boundedBuffer
+
+	stdoutFile *os.File
+	stderrFile *os.File
+
+	live *domain.OutputStream
+	node string
+
+	combined bool
+	lines []domain.OutputLine
+	maxLines int
+	maxLineBytes int
+	droppedLines int
+	partial map[string]*bytes.Buffer
+
+	// Set once the output was stored in the report; later writes (by commands
+	// that were abandoned) fail
+	closed bool
+	lock sync.Mutex
+}
+
+type streamWriter struct {
+	capture *outputCapture
+	stream string
+	buffer *boundedBuffer
+	file *os.File
+}
+
+func newOutputCapture(job *domain.Job, report *domain.RunReportItem) *outputCapture {
+	capture := &outputCapture{
+		stdout: newBoundedBuffer(job.MaxOutputBytes),
+		stderr: newBoundedBuffer(job.MaxOutputBytes),
+		live: report.Live,
+		node: report.NodeName(),
+		combined: job.CombinedOutput,
+		partial: map[string]*bytes.Buffer{
+			domain.STREAM_STDOUT: new(bytes.Buffer),
+			domain.STREAM_STDERR: new(bytes.Buffer),
+		},
+	}
+
+	if job.MaxOutputBytes > 0 {
+		// Assume lines of 80 characters on average
+		capture.maxLines = job.MaxOutputBytes / 80 + 1
+		capture.maxLineBytes = job.MaxOutputBytes
+	}
+
+	if job.SpoolOutput && len(job.OutputDirectory) > 0 {
+		if err := capture.spool(job, report); err != nil {
+			job.Logger.Error("Could not spool output to disk, keeping it in memory: %s", err)
+			capture.closeFiles()
+			capture.stdoutFile, capture.stderrFile = nil, nil
+		}
+	}
+
+	return capture
+}
+
+// Opens the files that the complete output streams are written to.
+func (c *outputCapture) spool(job *domain.Job, report *domain.RunReportItem) error {
+	directory := path.Join(job.OutputDirectory, job.Name)
+	if err := os.MkdirAll(directory, 0755); err != nil {
+		return err
+	}
+
+	var err error
+	if c.stdoutFile, err = os.Create(path.Join(directory, report.Id + ".stdout")); err != nil {
+		return err
+	}
+
+	if c.stderrFile, err = os.Create(path.Join(directory, report.Id + ".stderr")); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (c *outputCapture) closeFiles() {
+	for _, file := range []*os.File{c.stdoutFile, c.stderrFile} {
+		if file != nil {
+			file.Close()
+		}
+	}
+}
+
+func (c *outputCapture) Stdout() *streamWriter {
+	return &streamWriter{c, domain.STREAM_STDOUT, c.stdout, c.stdoutFile}
+}
+
+func (c *outputCapture) Stderr() *streamWriter {
+	return &streamWriter{c, domain.STREAM_STDERR, c.stderr, c.stderrFile}
+}
+
+func (w *streamWriter) Write(p []byte) (int, error) {
+	w.capture.lock.Lock()
+	defer w.capture.lock.Unlock()
+
+	if w.capture.closed {
+		return 0, io.ErrClosedPipe
+	}
+
+	if w.file != nil {
+		if _, err := w.file.Write(p); err != nil {
+			// Do not lose the output altogether
+			w.file = nil
+		}
+	}
+
+	if w.file == nil {
+		w.buffer.Write(p)
+	}
+
+	if w.capture.live != nil {
+		w.capture.live.Write(w.capture.node, w.stream, p)
+	}
+
+	if w.capture.combined {
+		w.capture.addLines(w.stream, p, time.Now())
+	}
+
+	return len(p), nil
+}
+
+func (c *outputCapture) addLines(stream string, p []byte, t time.Time) {
+	partial := c.partial[stream]
+	partial.Write(p)
+
+	for {
+		line, err := partial.ReadString('\n')
+		if err != nil {
+			// Keep incomplete lines until they are completed, but split
+			// lines that exceed the output limit
+			for c.maxLineBytes > 0 && len(line) >= c.maxLineBytes {
+				c.addLine(domain.OutputLine{Time: t, Stream: stream, Text: line[:c.maxLineBytes]})
+				line = line[c.maxLineBytes:]
+			}
+
+			partial.Reset()
+			partial.WriteString(line)
+			return
+		}
+
+		c.addLine(domain.OutputLine{Time: t, Stream: stream, Text: line[:len(line) - 1]})
+	}
+}
+
+// Records a line of the combined output. When the number of lines exceeds the
+// limit, the lines in the middle are dropped.
+func (c *outputCapture) addLine(line domain.OutputLine) {
+	c.lines = append(c.lines, line)
+
+	if c.maxLines > 0 && len(c.lines) > c.maxLines {
+		middle := c.maxLines / 2
+		copy(c.lines[middle:], c.lines[middle + 1:])
+		c.lines = c.lines[:len(c.lines) - 1]
+		c.droppedLines ++
+	}
+}
+
+// Stores the captured output in the report item and closes the capture.
+func (c *outputCapture) apply(report *domain.RunReportItem) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.closed = true
+	c.closeFiles()
+
+	if c.stdoutFile != nil {
+		report.OutputFile = c.stdoutFile.Name()
+	}
+	if c.stderrFile != nil {
+		report.StderrFile = c.stderrFile.Name()
+	}
+
+	report.Output = c.stdout.String()
+	report.Stderr = c.stderr.String()
+	report.Truncated = c.stdout.dropped > 0 || c.stderr.dropped > 0 || c.droppedLines > 0
+
+	if c.combined {
+		now := time.Now()
+		for _, stream := range []string{domain.STREAM_STDOUT, domain.STREAM_STDERR} {
+			if rest := c.partial[stream]; rest.Len() > 0 {
+				c.addLine(domain.OutputLine{Time: now, Stream: stream, Text: rest.String()})
+				rest.Reset()
+			}
+		}
+
+		lines := c.lines
+		if c.droppedLines > 0 {
+			middle := c.maxLines / 2
+			marker := domain.OutputLine{Time: lines[middle].Time, Text: fmt.Sprintf("[... %d lines truncated ...]", c.droppedLines)}
+
+			lines = make([]domain.OutputLine, 0, len(c.lines) + 1)
+			lines = append(lines, c.lines[:middle]...)
+			lines = append(lines, marker)
+			lines = append(lines, c.lines[middle:]...)
+		}
+		report.Combined = lines
+	}
+}
+
+// A buffer that keeps only the first and the last half of its limit when more
+// data is written to it (a limit of 0 keeps everything). The last half is kept
+// in a ring buffer, so that writes past the limit do not copy the kept data.
+type boundedBuffer struct {
+	limit int
+	head bytes.Buffer
+	tail []byte
+	tailStart int
+	tailLen int
+	dropped int
+}
+
+func newBoundedBuffer(limit int) *boundedBuffer {
+	return &boundedBuffer{limit: limit}
+}
+
+func (b *boundedBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+
+	if b.limit <= 0 {
+		return b.head.Write(p)
+	}
+
+	if room := b.limit / 2 - b.head.Len(); room > 0 {
+		if room > len(p) {
+			room = len(p)
+		}
+		b.head.Write(p[:room])
+		p = p[room:]
+	}
+
+	if len(p) == 0 {
+		return n, nil
+	}
+
+	keep := b.limit - b.limit / 2
+	if b.tail == nil {
+		b.tail = make([]byte, keep)
+	}
+
+	if len(p) >= keep {
+		b.dropped += b.tailLen + len(p) - keep
+		copy(b.tail, p[len(p) - keep:])
+		b.tailStart, b.tailLen = 0, keep
+		return n, nil
+	}
+
+	if overflow := b.tailLen + len(p) - keep; overflow > 0 {
+		b.dropped += overflow
+		b.tailStart = (b.tailStart + overflow) % keep
+		b.tailLen -= overflow
+	}
+
+	end := (b.tailStart + b.tailLen) % keep
+	copied := copy(b.tail[end:], p)
+	copy(b.tail, p[copied:])
+	b.tailLen += len(p)
+
+	return n, nil
+}
+
+// Returns the kept end of the data in order.
+func (b *boundedBuffer) tailBytes() []byte {
+	if b.tailStart + b.tailLen <= len(b.tail) {
+		return b.tail[b.tailStart:b.tailStart + b.tailLen]
+	}
+
+	result := make([]byte, 0, b.tailLen)
+	result = append(result, b.tail[b.tailStart:]...)
+	return append(result, b.tail[:b.tailLen - (len(b.tail) - b.tailStart)]...)
+}
+
+func (b *boundedBuffer) String() string {
+	if b.dropped == 0 {
+		return b.head.String() + string(b.tailBytes())
+	}
+	return fmt.Sprintf("%s\n[... %d bytes truncated ...]\n%s", b.head.String(), b.dropped, b.tailBytes())
+}
@@ -0,0 +1,100 @@
+package runner
+
+import (
+	"fmt"
+	"testing"
+	"github.com/martin-helmich/distcrond/domain"
+)
+
+func TestOutputCaptureInterleavesLinesOfBothStreams(t *testing.T) {
+	capture := newOutputCapture(&domain.Job{CombinedOutput: true}, &domain.RunReportItem{})
+
+	capture.Stdout().Write([]byte("first "))
+	capture.Stderr().Write([]byte("error\n"))
+	capture.Stdout().Write([]byte("line\nsecond line\nunterminated"))
+
+	report := domain.RunReportItem{}
+	capture.apply(&report)
+
+	if report.Output != "first line\nsecond line\nunterminated" {
+		t.Errorf("Unexpected stdout %q", report.Output)
+	}
+
+	if report.Stderr != "error\n" {
+		t.Errorf("Unexpected stderr %q", report.Stderr)
+	}
+
+	expected := []domain.OutputLine{
+		{Stream: domain.STREAM_STDERR, Text: "error"},
+		{Stream: domain.STREAM_STDOUT, Text: "first line"},
+		{Stream: domain.STREAM_STDOUT, Text: "second line"},
+		{Stream: domain.STREAM_STDOUT, Text: "unterminated"},
+	}
+
+	if len(report.Combined) != len(expected) {
+		t.Fatalf("Expected %d lines, got %d", len(expected), len(report.Combined))
+	}
+
+	for i, line := range report.Combined {
+		if line.Stream != expected[i].Stream || line.Text != expected[i].Text {
+			t.Errorf("Expected line %d to be %s %q, got %s %q", i, expected[i].Stream, expected[i].Text, line.Stream, line.Text)
+		}
+	}
+}
+
+func TestOutputCaptureKeepsBeginningAndEndOfLongOutput(t *testing.T) {
+	capture := newOutputCapture(&domain.Job{MaxOutputBytes: 10}, &domain.RunReportItem{})
+
+	capture.Stdout().Write([]byte("abc"))
+	capture.Stdout().Write([]byte("defghijklmnopqrstuvwxyz"))
+
+	report := domain.RunReportItem{}
+	capture.apply(&report)
+
+	if report.Output != "abcde\n[... 16 bytes truncated ...]\nvwxyz" {
+		t.Errorf("Unexpected stdout %q", report.Output)
+	}
+
+	if !report.Truncated {
+		t.Error("Expected output to be marked as truncated")
+	}
+}
+
+func TestBoundedBufferKeepsEndOfManySmallWrites(t *testing.T) {
+	buffer := newBoundedBuffer(11)
+
+	all := ""
+	for i := 0; i < 50; i ++ {
+		chunk := fmt.Sprintf("%d,", i)
+		buffer.Write([]byte(chunk))
+		all += chunk
+	}
+
+	expected := fmt.Sprintf("%s\n[... %d bytes truncated ...]\n%s", all[:5], len(all) - 11, all[len(all) - 6:])
+	if buffer.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, buffer.String())
+	}
+}
+
+func TestOutputCaptureSplitsLinesLongerThanTheLimit(t *testing.T) {
+	capture := newOutputCapture(&domain.Job{CombinedOutput: true, MaxOutputBytes: 100}, &domain.RunReportItem{})
+
+	for i := 0; i < 25; i ++ {
+		capture.Stdout().Write([]byte("0123456789"))
+	}
+
+	if size := capture.partial[domain.STREAM_STDOUT].Len(); size >= 100 {
+		t.Errorf("Expected incomplete line to be flushed at the limit, %d bytes are buffered", size)
+	}
+
+	report := domain.RunReportItem{}
+	capture.apply(&report)
+
+	if len(report.Combined) != 3 {
+		t.Fatalf("Expected 3 lines, got %d", len(report.Combined))
+	}
+
+	if len(report.Combined[0].Text) != 100 || len(report.Combined[2].Text) != 50 {
+		t.Errorf("Unexpected line lengths %d and %d", len(report.Combined[0].Text), len(report.Combined[2].Text))
+	}
+}
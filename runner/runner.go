@@ -4,10 +4,11 @@ import (
 	"github.com/martin-helmich/distcrond/domain"
 	"github.com/martin-helmich/distcrond/container"
 	"github.com/martin-helmich/distcrond/storage"
+	"time"
 )
 
 type JobRunner interface {
-	Run(job *domain.Job) error
+	Run(job *domain.Job, report *domain.RunReport) error
 }
 
 type GenericJobRunner struct {
@@ -19,23 +20,132 @@ type GenericJobRunner struct {
 type DispatchingRunner struct {
 	allRunner JobRunner
 	anyRunner JobRunner
+	someRunner JobRunner
 }
 
 func NewDispatchingRunner(nodes *container.NodeContainer, storage storage.StorageBackend, health HealthChecker) *DispatchingRunner {
 	return &DispatchingRunner{
 		allRunner: NewAllJobRunner(nodes, storage, health),
 		anyRunner: NewAnyJobRunner(nodes, storage, health),
+		someRunner: NewSomeJobRunner(nodes, storage, health),
 	}
 }
 
-func (d *DispatchingRunner) Run(job *domain.Job) error {
+func (d *DispatchingRunner) Run(job *domain.Job, report *domain.RunReport) error {
 	switch job.Policy.Hosts {
 	case domain.POLICY_ALL:
-		return d.allRunner.Run(job)
+		return d.allRunner.Run(job, report)
 
 	case domain.POLICY_ANY:
-		return d.anyRunner.Run(job)
+		return d.anyRunner.Run(job, report)
+
+	case domain.POLICY_SOME:
+		return d.someRunner.Run(job, report)
 	}
 
 	return nil
 }
+
+// Executes a job on a single node and stores the result in the given report
+// item. When the node turns out to be down, it is marked as such, a health
+// check is scheduled and the NodeDownError is returned. The caller must hold
+// a slot on the node (see acquireSlot).
+func executeOnNode(job *domain.Job, node *domain.Node, reportItem *domain.RunReportItem, health HealthChecker) error {
+	logger := job.Logger
+	logger.Debug("Executing on node %s\n", node.Name)
+
+	reportItem.Node = node
+	reportItem.Time.Start = time.Now()
+
+	defer func() {
+		reportItem.Time.Stop = time.Now()
+	}()
+
+	strat := node.ExecutionStrategy
+	if err := strat.ExecuteCommand(job, reportItem); err != nil {
+		switch err.(type) {
+		case NodeDownError:
+			func() {
+				node.Lock.Lock();
+				defer node.Lock.Unlock()
+
+				logger.Warning("Node %s is down.", node.Name)
+				node.Status = domain.STATUS_DOWN
+				node.DownError = err.(NodeDownError).Cause()
+			}()
+			health.ScheduleHealthCheck(node)
+		}
+
+		logger.Error("%s", err)
+		reportItem.Success = false
+		reportItem.Output = err.Error()
+		return err
+	}
+
+	logger.Debug("Done on %s\n", node.Name)
+	return nil
+}
+
+// Determines which retry condition (if any) applies to a finished attempt.
+// Returns an empty string if the attempt was successful.
+func failureCondition(reportItem *domain.RunReportItem, err error) string {
+	if _, ok := err.(NodeDownError); ok {
+		return domain.RETRY_ON_NODE_DOWN
+	}
+
+	switch {
+	case reportItem.Cancelled:
+		return ""
+	case reportItem.TimedOut:
+		return domain.RETRY_ON_TIMEOUT
+	case !reportItem.Success:
+		return domain.RETRY_ON_FAILURE
+	}
+
+	return ""
+}
+
+// Calls "attempt" until it succeeds or until the job's retry policy does not
+// allow any more attempts. Failed attempts are kept in the report item.
+func runWithRetries(job *domain.Job, reportItem *domain.RunReportItem, attempt func(*domain.RunReportItem) error) {
+	for {
+		err := attempt(reportItem)
+
+		condition := failureCondition(reportItem, err)
+		if condition == "" || !job.Retry.ShouldRetry(reportItem.Attempt, condition) {
+			return
+		}
+
+		backoff := job.Retry.BackoffFor(reportItem.Attempt)
+		job.Logger.Warning("Attempt %d failed (%s), retrying in %s", reportItem.Attempt, condition, backoff)
+
+		reportItem.BeginNextAttempt()
+
+		select {
+		case <-time.After(backoff):
+		case <-reportItem.Abort:
+			reportItem.Cancelled = true
+			reportItem.Output = "Run was cancelled before retrying"
+			return
+		}
+	}
+}
+
+// Records the completion of a run and persists its report.
+func finishRun(job *domain.Job, report *domain.RunReport, store storage.StorageBackend) {
+	report.Finalize()
+
+	job.Lock.Lock()
+	job.LastExecution = time.Now()
+	job.Lock.Unlock()
+
+	go func() {
+		if err := store.SaveReport(report); err != nil {
+			job.Logger.Error("%s", err)
+		}
+
+		if err := store.SaveJobState(job.State()); err != nil {
+			job.Logger.Error("%s", err)
+		}
+	}()
+}
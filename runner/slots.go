@@ -0,0 +1,57 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	"github.com/martin-helmich/distcrond/domain"
+)
+
+// Interval in which to check for free slots on saturated nodes
+const slotPollInterval = 100 * time.Millisecond
+
+// Takes a slot on the first of the given nodes that is not running as many
+// jobs as it may. When all nodes are saturated, waits until a slot becomes
+// free, but at most for the job's slot wait time. The caller must release the
+// slot after running the job.
+func acquireSlot(job *domain.Job, nodes []*domain.Node, reportItem *domain.RunReportItem) (*domain.Node, error) {
+	deadline := time.Now().Add(job.SlotWait)
+	logged := false
+
+	for {
+		for _, node := range nodes {
+			if node.TryAcquireSlot() {
+				return node, nil
+			}
+		}
+
+		if !logged {
+			job.Logger.Info("All %d candidate nodes are saturated, waiting for a free slot", len(nodes))
+			logged = true
+		}
+
+		if !time.Now().Before(deadline) {
+			break
+		}
+
+		select {
+		case <-time.After(slotPollInterval):
+		case <-reportItem.Abort:
+			reportItem.Cancelled = true
+			reportItem.Success = false
+			reportItem.Output = "Run was cancelled while waiting for a free slot"
+			return nil, errors.New(reportItem.Output)
+		}
+	}
+
+	if len(nodes) == 1 {
+		reportItem.Node = nodes[0]
+	}
+
+	reportItem.Time.Start = time.Now()
+	reportItem.Time.Stop = reportItem.Time.Start
+	reportItem.Success = false
+	reportItem.Output = fmt.Sprintf("Gave up after waiting %s for a free slot", job.SlotWait)
+
+	return nil, errors.New(reportItem.Output)
+}
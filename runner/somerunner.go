@@ -0,0 +1,121 @@
+package runner
+
+import (
+	"github.com/martin-helmich/distcrond/container"
+	"github.com/martin-helmich/distcrond/domain"
+	"github.com/martin-helmich/distcrond/storage"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type SomeJobRunner GenericJobRunner
+
+func NewSomeJobRunner(nodes *container.NodeContainer, storage storage.StorageBackend, health HealthChecker) JobRunner {
+	return &SomeJobRunner{nodes: nodes, storage: storage, healthChecker: health}
+}
+
+// Hands out candidate nodes that were not chosen initially, to replace
+// chosen nodes that turn out to be down.
+type sparePool struct {
+	nodes []*domain.Node
+	lock sync.Mutex
+}
+
+func (p *sparePool) take() *domain.Node {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if len(p.nodes) == 0 {
+		return nil
+	}
+
+	node := p.nodes[0]
+	p.nodes = p.nodes[1:]
+	return node
+}
+
+func (r *SomeJobRunner) Run(job *domain.Job, report *domain.RunReport) error {
+	logger := job.Logger
+	count := r.nodes.NodeCountForJob(job)
+	candidates := r.nodes.NodeCandidatesForJob(job)
+
+	if len(candidates) == 0 {
+		return errors.New(fmt.Sprintf("No nodes available for job %s", job.Name))
+	}
+
+	if len(candidates) < count {
+		logger.Warning("Only %d of %d required nodes are available", len(candidates), count)
+	}
+
+	chosen := candidates
+	spares := &sparePool{}
+	if len(candidates) > count {
+		chosen = candidates[:count]
+		spares.nodes = candidates[count:]
+	}
+
+	done := make(chan bool, count)
+	logger.Debug("Executing on %d of %d nodes", count, len(candidates))
+
+	report.Initialize(job, count)
+
+	for i := 0; i < count; i ++ {
+		var node *domain.Node
+		if i < len(chosen) {
+			node = chosen[i]
+		}
+
+		go func(node *domain.Node, reportItem *domain.RunReportItem) {
+			runWithRetries(job, reportItem, func(reportItem *domain.RunReportItem) error {
+				var err error
+				for {
+					if node == nil {
+						if node = spares.take(); node == nil {
+							break
+						}
+						logger.Notice("Falling back to node %s", node.Name)
+					}
+
+					if _, sErr := acquireSlot(job, []*domain.Node{node}, reportItem); sErr != nil {
+						return sErr
+					}
+
+					err = executeOnNode(job, node, reportItem, r.healthChecker)
+					node.ReleaseSlot()
+
+					if _, down := err.(NodeDownError); !down {
+						return err
+					}
+
+					node = nil
+				}
+
+				if err == nil {
+					reportItem.Time.Start = time.Now()
+					reportItem.Time.Stop = reportItem.Time.Start
+					reportItem.Success = false
+					reportItem.Output = "Could not find any node to run job on"
+					err = errors.New(reportItem.Output)
+				}
+
+				return err
+			})
+
+			logger.Info("Report: %s\n", reportItem.Summary())
+
+			done <- true
+		}(node, &report.Items[i])
+	}
+
+	for i := 0; i < count; i ++ {
+		<- done
+	}
+
+	finishRun(job, report, r.storage)
+
+	logger.Info("%s: Done on %d nodes", job.Name, count)
+
+	return nil
+}
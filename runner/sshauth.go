@@ -0,0 +1,210 @@
+package runner
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"github.com/martin-helmich/distcrond/domain"
+)
+
+// The credentials used to log in on a node. Keys, certificates and passwords
+// are read once when the node is configured; the agent is asked for its keys on
+// each connection.
+type sshCredentials struct {
+	signers []ssh.Signer
+	agent bool
+	password string
+	hasPassword bool
+}
+
+func newSshCredentials(options domain.ConnectionOptions) (*sshCredentials, error) {
+	credentials := &sshCredentials{agent: options.SshAgent}
+
+	if len(options.SshKeyFile) > 0 {
+		signer, err := readPrivateKey(options)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(options.SshCertificateFile) > 0 {
+			if signer, err = readCertificate(options.SshCertificateFile, signer); err != nil {
+				return nil, err
+			}
+		}
+
+		credentials.signers = append(credentials.signers, signer)
+	}
+
+	if options.HasSshPassword() {
+		password, err := readSecret(options.SshPasswordFile, options.SshPasswordEnv, "SSH password")
+		if err != nil {
+			return nil, err
+		}
+
+		credentials.password = password
+		credentials.hasPassword = true
+	}
+
+	return credentials, nil
+}
+
+func readPrivateKey(options domain.ConnectionOptions) (ssh.Signer, error) {
+	keyString, err := ioutil.ReadFile(options.SshKeyFile)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Could not read private key file %s: %s", options.SshKeyFile, err))
+	}
+
+	var signer ssh.Signer
+	if len(options.SshKeyPassphraseFile) > 0 || len(options.SshKeyPassphraseEnv) > 0 {
+		passphrase, passErr := readSecret(options.SshKeyPassphraseFile, options.SshKeyPassphraseEnv, "private key passphrase")
+		if passErr != nil {
+			return nil, passErr
+		}
+
+		signer, err = parseEncryptedPrivateKey(keyString, []byte(passphrase))
+	} else {
+		signer, err = ssh.ParsePrivateKey(keyString)
+	}
+
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Could not parse private key file %s: %s", options.SshKeyFile, err))
+	}
+
+	return signer, nil
+}
+
+// Decrypts a PEM encoded private key ("Proc-Type: 4,ENCRYPTED"), as written by
+// ssh-keygen -m PEM. Keys in the newer OpenSSH format cannot be decrypted.
+func parseEncryptedPrivateKey(keyString []byte, passphrase []byte) (ssh.Signer, error) {
+	block, _ := pem.Decode(keyString)
+	if block == nil {
+		return nil, errors.New("No PEM encoded key found")
+	}
+
+	if !x509.IsEncryptedPEMBlock(block) {
+		return nil, errors.New("Key is not encrypted, but a passphrase was configured")
+	}
+
+	der, err := x509.DecryptPEMBlock(block, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := ssh.ParseRawPrivateKey(pem.EncodeToMemory(&pem.Block{Type: block.Type, Bytes: der}))
+	if err != nil {
+		return nil, err
+	}
+
+	return ssh.NewSignerFromKey(key)
+}
+
+// Presents a certificate instead of the plain public key of a signer.
+type certSigner struct {
+	ssh.Signer
+	cert *ssh.Certificate
+}
+
+func (s *certSigner) PublicKey() ssh.PublicKey {
+	return s.cert
+}
+
+// Reads an OpenSSH user certificate for a private key.
+func readCertificate(filename string, signer ssh.Signer) (ssh.Signer, error) {
+	certString, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Could not read certificate file %s: %s", filename, err))
+	}
+
+	key, _, _, _, err := ssh.ParseAuthorizedKey(certString)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Could not parse certificate file %s: %s", filename, err))
+	}
+
+	cert, ok := key.(*ssh.Certificate)
+	if !ok || cert.CertType != ssh.UserCert {
+		return nil, errors.New(fmt.Sprintf("%s does not contain a user certificate", filename))
+	}
+
+	if !bytes.Equal(cert.Key.Marshal(), signer.PublicKey().Marshal()) {
+		return nil, errors.New(fmt.Sprintf("Certificate %s does not match the private key", filename))
+	}
+
+	return &certSigner{Signer: signer, cert: cert}, nil
+}
+
+// Reads a secret either from a file or from an environment variable. Trailing
+// line breaks are removed from files.
+func readSecret(filename string, env string, purpose string) (string, error) {
+	if len(filename) > 0 {
+		contents, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return "", errors.New(fmt.Sprintf("Could not read %s from %s: %s", purpose, filename, err))
+		}
+		return strings.TrimRight(string(contents), "\r\n"), nil
+	}
+
+	value, ok := os.LookupEnv(env)
+	if !ok {
+		return "", errors.New(fmt.Sprintf("Environment variable %s with %s is not set", env, purpose))
+	}
+
+	return value, nil
+}
+
+// Builds the authentication methods for a new connection, in the order in
+// which they are tried. The returned function must be called once the
+// connection is established.
+func (c *sshCredentials) authMethods() ([]ssh.AuthMethod, func(), error) {
+	methods := make([]ssh.AuthMethod, 0, 3)
+	cleanup := func() {}
+
+	signers := c.signers
+	if c.agent {
+		socket := os.Getenv("SSH_AUTH_SOCK")
+		if len(socket) == 0 {
+			return nil, nil, errors.New("SSH agent requested, but SSH_AUTH_SOCK is not set")
+		}
+
+		conn, err := net.Dial("unix", socket)
+		if err != nil {
+			return nil, nil, errors.New(fmt.Sprintf("Could not connect to SSH agent: %s", err))
+		}
+		cleanup = func() { conn.Close() }
+
+		agentSigners, err := agent.NewClient(conn).Signers()
+		if err != nil {
+			conn.Close()
+			return nil, nil, errors.New(fmt.Sprintf("Could not get keys from SSH agent: %s", err))
+		}
+
+		signers = append(append([]ssh.Signer{}, signers...), agentSigners...)
+	}
+
+	// All keys must be offered within one method, as each method is only
+	// tried once.
+	if len(signers) > 0 {
+		methods = append(methods, ssh.PublicKeys(signers...))
+	}
+
+	if c.hasPassword {
+		password := c.password
+		methods = append(methods, ssh.KeyboardInteractive(func(_, _ string, questions []string, _ []bool) ([]string, error) {
+			answers := make([]string, len(questions))
+			for i := range answers {
+				answers[i] = password
+			}
+			return answers, nil
+		}))
+		methods = append(methods, ssh.Password(password))
+	}
+
+	return methods, cleanup, nil
+}
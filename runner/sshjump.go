@@ -0,0 +1,202 @@
+package runner
+
+import (
+	"net"
+	"sync"
+	"golang.org/x/crypto/ssh"
+	"github.com/martin-helmich/distcrond/domain"
+	logging "github.com/op/go-logging"
+)
+
+// A host to connect to via SSH, and the way to log in there.
+type sshEndpoint struct {
+	address string
+	user string
+	credentials *sshCredentials
+	hostKeys *hostKeyVerifier
+}
+
+func newSshEndpoint(options domain.ConnectionOptions, config SshConfig) (*sshEndpoint, error) {
+	credentials, err := newSshCredentials(options)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sshEndpoint{
+		address: options.SshHost,
+		user: options.SshUser,
+		credentials: credentials,
+		hostKeys: newHostKeyVerifier(options, config),
+	}, nil
+}
+
+// Connects to the host, either directly or through another connection. A host
+// key mismatch is returned as such, so that it can be told apart from other
+// connection problems.
+func (e *sshEndpoint) connect(through *ssh.Client) (*ssh.Client, error) {
+	var mismatch *domain.HostKeyMismatchError
+
+	auth, cleanup, err := e.credentials.authMethods()
+	if err != nil {
+		return nil, err
+	}
+
+	defer cleanup()
+
+	config := &ssh.ClientConfig{
+		User: e.user,
+		Auth: auth,
+		HostKeyCallback: func(_ string, _ net.Addr, key ssh.PublicKey) error {
+			err := e.hostKeys.Verify(key)
+			if m, ok := err.(*domain.HostKeyMismatchError); ok {
+				mismatch = m
+			}
+			return err
+		},
+	}
+
+	var conn net.Conn
+	if through == nil {
+		conn, err = net.Dial("tcp", e.address)
+	} else {
+		conn, err = through.Dial("tcp", e.address)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	c, chans, reqs, err := ssh.NewClientConn(conn, e.address, config)
+	if mismatch != nil {
+		conn.Close()
+		return nil, mismatch
+	} else if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return ssh.NewClient(c, chans, reqs), nil
+}
+
+// A hop in a chain of jump hosts. Hops are identified by their host and user,
+// and by the hops before them.
+type jumpHost struct {
+	id string
+	endpoint *sshEndpoint
+	previous *jumpHost
+}
+
+func newJumpHost(options domain.ConnectionOptions, config SshConfig, previous *jumpHost) (*jumpHost, error) {
+	endpoint, err := newSshEndpoint(options, config)
+	if err != nil {
+		return nil, err
+	}
+
+	id := options.SshUser + "@" + options.SshHost
+	if previous != nil {
+		id = previous.id + ">" + id
+	}
+
+	return &jumpHost{id, endpoint, previous}, nil
+}
+
+// An open connection to a jump host. Connections are shared between all nodes
+// behind the same jump host, and closed when no node uses them anymore.
+type jumpConnection struct {
+	hop *jumpHost
+	client *ssh.Client
+	previous *jumpConnection
+	refs int
+}
+
+var jumpConnections = struct {
+	lock sync.Mutex
+	open map[string]*jumpConnection
+}{open: make(map[string]*jumpConnection)}
+
+// Returns an open connection to a jump host, connecting to it (and the hops
+// before it) if necessary.
+func acquireJumpConnection(hop *jumpHost) (*jumpConnection, error) {
+	jumpConnections.lock.Lock()
+	if conn, ok := jumpConnections.open[hop.id]; ok {
+		conn.refs ++
+		jumpConnections.lock.Unlock()
+		return conn, nil
+	}
+	jumpConnections.lock.Unlock()
+
+	var previous *jumpConnection
+	var through *ssh.Client
+	if hop.previous != nil {
+		var err error
+		if previous, err = acquireJumpConnection(hop.previous); err != nil {
+			return nil, err
+		}
+		through = previous.client
+	}
+
+	// Do not block other connections while connecting
+	client, err := hop.endpoint.connect(through)
+	if err != nil {
+		if previous != nil {
+			previous.release()
+		}
+		return nil, err
+	}
+
+	jumpConnections.lock.Lock()
+	defer jumpConnections.lock.Unlock()
+
+	if conn, ok := jumpConnections.open[hop.id]; ok {
+		// Somebody else connected in the meantime
+		client.Close()
+		if previous != nil {
+			previous.releaseLocked()
+		}
+		conn.refs ++
+		return conn, nil
+	}
+
+	conn := &jumpConnection{hop: hop, client: client, previous: previous, refs: 1}
+	jumpConnections.open[hop.id] = conn
+
+	logger, _ := logging.GetLogger("ssh")
+	logger.Debug("Opened SSH connection to jump host %s", hop.id)
+
+	go func() {
+		client.Wait()
+
+		jumpConnections.lock.Lock()
+		defer jumpConnections.lock.Unlock()
+
+		if jumpConnections.open[hop.id] == conn {
+			delete(jumpConnections.open, hop.id)
+		}
+	}()
+
+	return conn, nil
+}
+
+// Gives up one use of the connection. The last one closes it.
+func (c *jumpConnection) release() {
+	jumpConnections.lock.Lock()
+	defer jumpConnections.lock.Unlock()
+
+	c.releaseLocked()
+}
+
+func (c *jumpConnection) releaseLocked() {
+	c.refs --
+	if c.refs > 0 {
+		return
+	}
+
+	if jumpConnections.open[c.hop.id] == c {
+		delete(jumpConnections.open, c.hop.id)
+	}
+	c.client.Close()
+
+	if c.previous != nil {
+		c.previous.releaseLocked()
+	}
+}
@@ -0,0 +1,238 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+	"golang.org/x/crypto/ssh"
+	logging "github.com/op/go-logging"
+)
+
+// A pool of SSH connections to a single node. Sessions are multiplexed over
+// long-lived connections; another connection is only opened when all existing
+// connections carry the maximum number of sessions. Connections that fail (or
+// whose peer stops answering keepalives) are discarded and replaced
+// transparently on the next request.
+type sshPool struct {
+	name string
+	dial func() (*ssh.Client, error)
+	maxSessions int
+	keepAlive time.Duration
+	logger *logging.Logger
+
+	connections []*sshConnection
+	closed bool
+	lock sync.Mutex
+}
+
+type sshConnection struct {
+	client *ssh.Client
+	sessions int
+
+	// Set when no new sessions may be opened on the connection
+	broken bool
+	done chan struct{}
+}
+
+func newSshPool(name string, dial func() (*ssh.Client, error), maxSessions int, keepAlive time.Duration) *sshPool {
+	logger, _ := logging.GetLogger("ssh")
+
+	return &sshPool{
+		name: name,
+		dial: dial,
+		maxSessions: maxSessions,
+		keepAlive: keepAlive,
+		logger: logger,
+	}
+}
+
+// Opens a new session. The returned function must be called when the session
+// is no longer needed. When opening the session on an existing connection
+// fails, the connection is discarded and the session is opened on a new one.
+func (p *sshPool) Session() (*ssh.Session, func(), error) {
+	var lastErr error
+
+	for attempt := 0; attempt < 2; attempt ++ {
+		conn, err := p.acquire()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		session, err := conn.client.NewSession()
+		if err == nil {
+			return session, func() {
+				session.Close()
+				p.release(conn)
+			}, nil
+		}
+
+		p.logger.Warning("Could not start SSH session on %s, reconnecting: %s", p.name, err)
+		p.discard(conn)
+		p.release(conn)
+		lastErr = err
+	}
+
+	return nil, nil, errors.New(fmt.Sprintf("Could not start SSH session: %s", lastErr))
+}
+
+// Takes a session slot on a connection with free slots, connecting to the
+// node if there is none.
+func (p *sshPool) acquire() (*sshConnection, error) {
+	p.lock.Lock()
+
+	if p.closed {
+		p.lock.Unlock()
+		return nil, errors.New("Connection pool is closed")
+	}
+
+	for _, conn := range p.connections {
+		if p.maxSessions == 0 || conn.sessions < p.maxSessions {
+			conn.sessions ++
+			p.lock.Unlock()
+			return conn, nil
+		}
+	}
+
+	p.lock.Unlock()
+
+	// Do not block other sessions while connecting
+	client, err := p.dial()
+	if err != nil {
+		return nil, err
+	}
+
+	conn := &sshConnection{client: client, sessions: 1, done: make(chan struct{})}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.closed {
+		client.Close()
+		return nil, errors.New("Connection pool is closed")
+	}
+
+	p.logger.Debug("Opened SSH connection to %s (%d connections)", p.name, len(p.connections) + 1)
+	p.connections = append(p.connections, conn)
+
+	go p.watch(conn)
+	if p.keepAlive > 0 {
+		go p.sendKeepAlives(conn)
+	}
+
+	return conn, nil
+}
+
+// Frees a session slot. Idle connections are closed when they are broken or
+// when there are other connections to the node.
+func (p *sshPool) release(conn *sshConnection) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	conn.sessions --
+	if conn.sessions > 0 {
+		return
+	}
+
+	if !conn.broken && len(p.connections) > 1 {
+		p.remove(conn)
+	}
+
+	if conn.broken {
+		conn.client.Close()
+	}
+}
+
+// Prevents new sessions from being opened on a connection. The connection is
+// closed as soon as its sessions have completed.
+func (p *sshPool) discard(conn *sshConnection) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if conn.broken {
+		return
+	}
+
+	p.remove(conn)
+
+	if conn.sessions == 0 {
+		conn.client.Close()
+	}
+}
+
+// Must be called with the lock held.
+func (p *sshPool) remove(conn *sshConnection) {
+	conn.broken = true
+	close(conn.done)
+
+	for i, c := range p.connections {
+		if c == conn {
+			p.connections = append(p.connections[:i], p.connections[i + 1:]...)
+			break
+		}
+	}
+}
+
+// Discards a connection as soon as it is closed, for whatever reason.
+func (p *sshPool) watch(conn *sshConnection) {
+	err := conn.client.Wait()
+
+	select {
+	case <-conn.done:
+	default:
+		p.logger.Warning("SSH connection to %s was closed: %v", p.name, err)
+		p.discard(conn)
+	}
+}
+
+// Periodically checks that the peer is still responding. Connections to a peer
+// that does not respond within the keepalive interval are closed, which also
+// aborts the commands that are still running on them.
+func (p *sshPool) sendKeepAlives(conn *sshConnection) {
+	ticker := time.NewTicker(p.keepAlive)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-conn.done:
+			return
+		case <-ticker.C:
+		}
+
+		response := make(chan error, 1)
+		go func() {
+			_, _, err := conn.client.SendRequest("keepalive@openssh.com", true, nil)
+			response <- err
+		}()
+
+		var err error
+		select {
+		case err = <-response:
+		case <-time.After(p.keepAlive):
+			err = errors.New(fmt.Sprintf("No response within %s", p.keepAlive))
+		}
+
+		if err != nil {
+			p.logger.Warning("SSH connection to %s is dead: %s", p.name, err)
+			p.discard(conn)
+			conn.client.Close()
+			return
+		}
+	}
+}
+
+// Closes all connections, including those with running sessions.
+func (p *sshPool) Close() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.closed = true
+	for _, conn := range p.connections {
+		conn.broken = true
+		close(conn.done)
+		conn.client.Close()
+	}
+	p.connections = nil
+
+	return nil
+}
@@ -0,0 +1,45 @@
+package scheduler
+
+import (
+	. "github.com/martin-helmich/distcrond/domain"
+	"github.com/martin-helmich/distcrond/logging"
+	"time"
+)
+
+// Restores the persisted state of each job and replays the runs that were
+// missed since the last execution, according to the job's catch-up policy.
+func (s *Scheduler) catchUp(wrappers []*JobWrapper, now time.Time) {
+	for _, wrapper := range wrappers {
+		job := wrapper.job
+
+		state, err := s.storage.LoadJobState(job)
+		if err != nil {
+			logging.Error("Could not load state of job %s: %s", job.Name, err)
+			continue
+		}
+
+		job.RestoreState(state)
+
+		missed := job.CatchUp.MissedRuns(job.Schedule, state.HandledUntil(), now)
+		if len(missed) == 0 {
+			continue
+		}
+
+		logging.Notice("Catching up on %d missed runs of job %s", len(missed), job.Name)
+
+		go func(wrapper *JobWrapper, missed []time.Time) {
+			for _, scheduledFor := range missed {
+				// Leadership might have been lost in the meantime
+				if !s.IsStarted() {
+					return
+				}
+
+				report := NewRunReport(wrapper.job)
+				report.CatchUp = true
+				report.ScheduledFor = scheduledFor
+
+				wrapper.Dispatch(report)
+			}
+		}(wrapper, missed)
+	}
+}
@@ -0,0 +1,27 @@
+package scheduler
+
+import (
+	. "github.com/martin-helmich/distcrond/domain"
+)
+
+// Dispatches the downstream jobs of a finished run, if their trigger
+// condition is met. This also happens when the scheduler was stopped while
+// the run was active, as no other instance knows about the run.
+func (s *Scheduler) triggerDownstream(report *RunReport) {
+	for _, name := range report.Job.Downstream {
+		s.wrappersLock.RLock()
+		wrapper, ok := s.wrappers[name]
+		s.wrappersLock.RUnlock()
+
+		if !ok || !wrapper.job.Dependency.TriggeredBy(report) {
+			continue
+		}
+
+		report.Job.Logger.Info("Run %s (%s) triggers downstream job %s", report.Id, report.Status(), name)
+
+		downstream := NewRunReport(wrapper.job)
+		downstream.TriggeredBy = report.Id
+
+		go wrapper.Dispatch(downstream)
+	}
+}
@@ -0,0 +1,223 @@
+package scheduler
+
+import (
+	"fmt"
+	"github.com/martin-helmich/distcrond/container"
+	. "github.com/martin-helmich/distcrond/domain"
+	"github.com/martin-helmich/distcrond/runner"
+	"github.com/martin-helmich/distcrond/storage"
+	"sync"
+	"time"
+)
+
+// Wraps a job for the cron scheduler and enforces the job's concurrency
+// policy when the job is due while previous runs are still active.
+type JobWrapper struct {
+	runner runner.JobRunner
+	storage storage.StorageBackend
+	runs *container.RunContainer
+	maintenance *container.MaintenanceContainer
+	job *Job
+
+	lock sync.Mutex
+	slots chan bool
+	running map[string]*RunReport
+	pending map[string]*RunReport
+	active sync.WaitGroup
+
+	// Called after each finished run
+	completed func(report *RunReport)
+}
+
+func NewJobWrapper(job *Job, runner runner.JobRunner, storage storage.StorageBackend, runs *container.RunContainer) *JobWrapper {
+	return &JobWrapper{
+		runner: runner,
+		storage: storage,
+		runs: runs,
+		job: job,
+		slots: make(chan bool, job.Concurrency.Slots()),
+		running: make(map[string]*RunReport),
+		pending: make(map[string]*RunReport),
+	}
+}
+
+// Called by the cron scheduler when the job is due.
+func (w *JobWrapper) Run() {
+	w.Dispatch(NewRunReport(w.job))
+}
+
+// Runs the job, unless the job is paused or the concurrency policy requires
+// the run to be skipped. Blocks until the run is completed (or skipped).
+func (w *JobWrapper) Dispatch(report *RunReport) {
+	w.dispatch(report, true)
+}
+
+// Runs the job on explicit request. Manual runs are never skipped, but still
+// wait for a free slot when previous runs are active.
+func (w *JobWrapper) DispatchManually(report *RunReport) {
+	w.dispatch(report, false)
+}
+
+func (w *JobWrapper) dispatch(report *RunReport, enforcePolicy bool) {
+	policy := w.job.Concurrency
+
+	if enforcePolicy {
+		w.refreshPaused()
+	}
+
+	if enforcePolicy && w.job.IsPaused() {
+		w.job.Logger.Info("Job is paused, not dispatching run %s", report.Id)
+		return
+	}
+
+	w.runs.AddRun(report)
+	defer report.SetPhase(RUN_FINISHED)
+
+	if enforcePolicy && w.maintenance != nil {
+		if window := w.maintenance.ActiveWindowForJob(w.job, w.scheduledFor(report)); window != nil {
+			// Skipped runs must not be caught up on after the window
+			w.recordDispatch(report)
+			w.skip(report, fmt.Sprintf("Maintenance window %s is active", window.Id))
+			return
+		}
+	}
+
+	w.lock.Lock()
+
+	active := len(w.running) + len(w.pending)
+	mode := policy.Mode
+	if !enforcePolicy {
+		mode = ""
+	}
+
+	switch mode {
+	case CONCURRENCY_FORBID:
+		if active > 0 {
+			w.lock.Unlock()
+			w.skip(report, "Previous run is still active")
+			return
+		}
+
+	case CONCURRENCY_QUEUE:
+		if len(w.pending) >= policy.Limit {
+			w.lock.Unlock()
+			w.skip(report, "Too many runs waiting for previous runs to complete")
+			return
+		}
+
+	case CONCURRENCY_ALLOW:
+		if active >= policy.Limit {
+			w.lock.Unlock()
+			w.skip(report, "Too many runs active at the same time")
+			return
+		}
+
+	case CONCURRENCY_REPLACE:
+		for _, previous := range w.pending {
+			previous.Cancel()
+		}
+		for _, previous := range w.running {
+			w.job.Logger.Notice("Cancelling run %s, replaced by run %s", previous.Id, report.Id)
+			previous.Cancel()
+		}
+	}
+
+	w.pending[report.Id] = report
+	w.active.Add(1)
+	w.lock.Unlock()
+
+	defer w.active.Done()
+
+	w.slots <- true
+	defer func() { <- w.slots }()
+
+	w.lock.Lock()
+	delete(w.pending, report.Id)
+	if report.IsCancelled() {
+		w.lock.Unlock()
+		w.skip(report, "Replaced by a newer run before starting")
+		return
+	}
+	w.running[report.Id] = report
+	w.lock.Unlock()
+
+	report.SetPhase(RUN_RUNNING)
+	w.recordDispatch(report)
+
+	err := w.runner.Run(w.job, report)
+
+	w.lock.Lock()
+	delete(w.running, report.Id)
+	w.lock.Unlock()
+
+	if err != nil {
+		w.job.Logger.Error("%s", err)
+		w.fail(report, err.Error())
+	}
+
+	if w.completed != nil {
+		w.completed(report)
+	}
+}
+
+// Checks if runs of the job are running or waiting to be run.
+func (w *JobWrapper) IsActive() bool {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	return len(w.running) + len(w.pending) > 0
+}
+
+// Blocks until all active runs of the job have completed.
+func (w *JobWrapper) Wait() {
+	w.active.Wait()
+}
+
+func (w *JobWrapper) skip(report *RunReport, reason string) {
+	w.job.Logger.Warning("Skipping run %s: %s", report.Id, reason)
+
+	report.Skip(reason)
+	if err := w.storage.SaveReport(report); err != nil {
+		w.job.Logger.Error("%s", err)
+	}
+}
+
+// Records a run that could not be executed at all.
+func (w *JobWrapper) fail(report *RunReport, reason string) {
+	report.Fail(reason)
+	if err := w.storage.SaveReport(report); err != nil {
+		w.job.Logger.Error("%s", err)
+	}
+}
+
+// Persists that the run was started, so that no other instance catches up on
+// it when taking over while the run is still active.
+func (w *JobWrapper) recordDispatch(report *RunReport) {
+	if report.Manual {
+		return
+	}
+
+	w.job.SetLastDispatch(w.scheduledFor(report))
+	if err := w.storage.SaveJobState(w.job.State()); err != nil {
+		w.job.Logger.Error("%s", err)
+	}
+}
+
+// Reads the paused flag from the storage, as the job might have been paused
+// or resumed through another instance.
+func (w *JobWrapper) refreshPaused() {
+	state, err := w.storage.LoadJobState(w.job)
+	if err != nil {
+		w.job.Logger.Warning("Could not load state of job %s, keeping the paused flag as it is: %s", w.job.Name, err)
+		return
+	}
+
+	w.job.SetPaused(state.Paused)
+}
+
+func (w *JobWrapper) scheduledFor(report *RunReport) time.Time {
+	if report.ScheduledFor.IsZero() {
+		return time.Now()
+	}
+	return report.ScheduledFor
+}
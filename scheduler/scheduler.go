@@ -1,37 +1,46 @@
 package scheduler
 
 import (
-//	"time"
+	"time"
 	"github.com/martin-helmich/distcrond/container"
-	. "github.com/martin-helmich/distcrond/domain"
 	"github.com/martin-helmich/distcrond/runner"
 	"github.com/martin-helmich/distcrond/logging"
-//	"sync/atomic"
+	"github.com/martin-helmich/distcrond/storage"
 	"github.com/robfig/cron"
+	"errors"
+	"fmt"
+	"sync"
+	. "github.com/martin-helmich/distcrond/domain"
 )
 
 type Scheduler struct {
 	jobContainer *container.JobContainer
 	nodeContainer *container.NodeContainer
+	maintenance *container.MaintenanceContainer
 	runner runner.JobRunner
+	storage storage.StorageBackend
+	runs *container.RunContainer
 	abort chan bool
 
-	Done chan bool
-}
+	cron *cron.Cron
+	wrappers map[string]*JobWrapper
+	retired []*JobWrapper
+	wrappersLock sync.RWMutex
+	started bool
 
-type JobWrapper struct {
-	runner runner.JobRunner
-	job *Job
-	semaphore chan bool
+	Done chan bool
 }
 
-func NewScheduler(jobs *container.JobContainer, nodes *container.NodeContainer, runner runner.JobRunner) *Scheduler {
+func NewScheduler(jobs *container.JobContainer, nodes *container.NodeContainer, maintenance *container.MaintenanceContainer, runner runner.JobRunner, storage storage.StorageBackend, runs *container.RunContainer) *Scheduler {
 	return &Scheduler {
-		jobs,
-		nodes,
-		runner,
-		make(chan bool),
-		make(chan bool),
+		jobContainer: jobs,
+		nodeContainer: nodes,
+		maintenance: maintenance,
+		runner: runner,
+		storage: storage,
+		runs: runs,
+		abort: make(chan bool),
+		Done: make(chan bool),
 	}
 }
 
@@ -57,89 +66,173 @@ func (s *Scheduler) Abort() {
 //	return todayReference
 //}
 
+// Starts scheduling jobs and blocks until the scheduler is aborted.
 func (s *Scheduler) Run() {
+	s.Start()
+	s.RunStandby()
+}
+
+// Blocks until the scheduler is aborted, without starting to schedule jobs.
+// In high-availability mode, jobs are only scheduled (using Start and Stop)
+// while this instance is the leader.
+func (s *Scheduler) RunStandby() {
+	select {
+	case <- s.abort:
+		logging.Notice("Aborting")
+
+		logging.Debug("Stopping scheduler...")
+		s.Stop()
+
+		s.wrappersLock.RLock()
+		wrappers := append([]*JobWrapper{}, s.retired...)
+		for _, wrapper := range s.wrappers {
+			wrappers = append(wrappers, wrapper)
+		}
+		s.wrappersLock.RUnlock()
+
+		logging.Notice("Waiting for running jobs...")
+		for _, wrapper := range wrappers {
+			wrapper.Wait()
+		}
+
+		logging.Debug("Done")
+		s.Done <- true
+	}
+}
+
+// Starts scheduling jobs, including runs that were missed while no instance
+// was scheduling them.
+func (s *Scheduler) Start() {
+	s.wrappersLock.Lock()
+	if s.started {
+		s.wrappersLock.Unlock()
+		return
+	}
+	s.started = true
+	s.wrappersLock.Unlock()
+
 	logging.Info("Starting scheduler")
 
-	var jobCount   int               = s.jobContainer.Count()
-	var semaphores []chan bool       = make([]chan bool, jobCount)
-//	var tickers    chan *time.Ticker = make(chan *time.Ticker, jobCount)
-//	var now        time.Time         = time.Now()
+	wrappers := s.schedule()
+	s.catchUp(wrappers, time.Now())
+}
 
-//	var startedTickers int64 = 0
+// Stops scheduling jobs. Runs that are in progress are not interrupted; the
+// wrappers are kept, so that their concurrency policies still apply when the
+// scheduler is started again.
+func (s *Scheduler) Stop() {
+	s.wrappersLock.Lock()
+	defer s.wrappersLock.Unlock()
 
-//	withLock := func(f func(), i int) {
-//		semaphores[i] <- true
-//		f()
-//		<-semaphores[i]
-//	}
+	if !s.started {
+		return
+	}
 
-//	var start []time.Time = make([]time.Time, jobCount)
-//	for i := 0; i < jobCount; i ++ {
-//		start[i] = s.nextRunDate(s.jobContainer.Get(i), now)
-//	}
+	logging.Info("Stopping scheduler")
 
-	cron := cron.New()
+	s.started = false
+	s.cron.Stop()
+	s.cron = nil
+}
 
-	for i := 0; i < jobCount; i ++ {
-		job := s.jobContainer.Get(i)
-		semaphores[i] = make(chan bool, 1)
+func (s *Scheduler) IsStarted() bool {
+	s.wrappersLock.RLock()
+	defer s.wrappersLock.RUnlock()
 
-		cmd := JobWrapper{runner: s.runner, job: job, semaphore: semaphores[i]}
-		cron.Schedule(job.Schedule, cmd)
+	return s.started
+}
 
-//		go func(job *Job, i int) {
-//			wait := start[i].Sub(now)
-//			logging.Debug("Next execution of %s scheduled for %s, waiting %s", job.Name, start[i].String(), wait.String())
-//			<- time.After(wait)
-//
-//			atomic.AddInt64(&startedTickers, 1)
-//
-//			ticker := time.NewTicker(job.Schedule.Interval)
-//			tickers <- ticker
-//
-//			logging.Debug("Started timer for %s", job.Name)
-//
-//			runJob := func(t time.Time) {
-//				withLock(func() {
-//					logging.Notice("Executing job %s at %s", job.Name, t)
-//					s.runner.Run(job)
-//				}, i)
-//			}
-//
-//			runJob(time.Now())
-//
-//			for t := range ticker.C {
-//				runJob(t)
-//			}
-//		}(job, i)
+// Re-schedules all jobs after the job container was updated. Runs that are in
+// progress are not interrupted.
+func (s *Scheduler) Reload() {
+	if s.IsStarted() {
+		logging.Notice("Rescheduling jobs")
+		s.schedule()
 	}
+}
 
-	cron.Start()
+// Builds a new cron schedule from the job container and starts it. Wrappers
+// of jobs that did not change are kept, so that their concurrency policies
+// still apply to runs that are in progress. Returns the wrappers of all jobs.
+func (s *Scheduler) schedule() []*JobWrapper {
+	s.wrappersLock.Lock()
+	defer s.wrappersLock.Unlock()
 
-	select {
-	case <- s.abort:
-		logging.Notice("Aborting")
+	if !s.started {
+		return nil
+	}
 
-//		logging.Debug("Stopping tickers...")
-//		for i := atomic.LoadInt64(&startedTickers); i > 0; i -- {
-//			(<- tickers).Stop()
-//		}
+	jobs := s.jobContainer.All()
+	wrappers := make([]*JobWrapper, 0, len(jobs))
+	wrappersByName := make(map[string]*JobWrapper)
 
-		logging.Debug("Stopping scheduler...")
-		cron.Stop()
+	cron := cron.New()
 
-		logging.Notice("Waiting for running jobs...")
-		for i := 0; i < jobCount; i ++ {
-			semaphores[i] <- true
+	for _, job := range jobs {
+		wrapper, ok := s.wrappers[job.Name]
+		if !ok || wrapper.job != job {
+			wrapper = NewJobWrapper(job, s.runner, s.storage, s.runs)
+			wrapper.completed = s.triggerDownstream
+			wrapper.maintenance = s.maintenance
 		}
 
-		logging.Debug("Done")
-		s.Done <- true
+		wrappers = append(wrappers, wrapper)
+		wrappersByName[job.Name] = wrapper
+
+		if job.Schedule != nil {
+			cron.Schedule(job.Schedule, wrapper)
+		}
+	}
+
+	// Keep track of replaced wrappers until their last run is completed
+	retired := make([]*JobWrapper, 0, len(s.retired))
+	for _, wrapper := range s.retired {
+		if wrapper.IsActive() {
+			retired = append(retired, wrapper)
+		}
+	}
+	for name, wrapper := range s.wrappers {
+		if current, ok := wrappersByName[name]; (!ok || current != wrapper) && wrapper.IsActive() {
+			retired = append(retired, wrapper)
+		}
+	}
+
+	if s.cron != nil {
+		s.cron.Stop()
 	}
+
+	s.cron = cron
+	s.wrappers = wrappersByName
+	s.retired = retired
+
+	cron.Start()
+
+	return wrappers
 }
 
-func (w JobWrapper) Run() {
-	w.semaphore <- true
-	w.runner.Run(w.job)
-	<- w.semaphore
-}
\ No newline at end of file
+// Starts a run of a job immediately (or as soon as previous runs of the job
+// are completed). Returns without waiting for the run to complete.
+func (s *Scheduler) Trigger(job *Job) (*RunReport, error) {
+	s.wrappersLock.RLock()
+	wrapper, ok := s.wrappers[job.Name]
+	started := s.started
+	s.wrappersLock.RUnlock()
+
+	if !started {
+		return nil, errors.New("Scheduler is not started on this instance")
+	}
+
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("Job %s is not scheduled", job.Name))
+	}
+
+	report := NewRunReport(job)
+	report.Manual = true
+
+	// Register the run before returning, so that it can be looked up right away
+	s.runs.AddRun(report)
+
+	go wrapper.DispatchManually(report)
+
+	return report, nil
+}
@@ -20,11 +20,27 @@ type JobOwnerResource struct {
 type RoleExecutionPolicyResource struct {
 	Hosts string `json:"select"`
 	Roles []string `json:"roles"`
+	Selection string `json:"selection,omitempty"`
+	Count int `json:"count,omitempty"`
+	Percent int `json:"percent,omitempty"`
 }
 
 type HostsExecutionPolicyResource struct {
 	Hosts string `json:"select"`
 	HostList []string `json:"hosts"`
+	Selection string `json:"selection,omitempty"`
+	Count int `json:"count,omitempty"`
+	Percent int `json:"percent,omitempty"`
+}
+
+type ConcurrencyPolicyResource struct {
+	Mode string `json:"mode"`
+	Limit int `json:"limit"`
+}
+
+type JobReferenceResource struct {
+	Name string `json:"name"`
+	Href string `json:"href"`
 }
 
 type DateResource struct {
@@ -40,9 +56,26 @@ type JobResource struct {
 	Owners []JobOwnerResource `json:"owners"`
 	Policy interface {} `json:"execution_policy"`
 	Schedule string `json:"execution_schedule"`
+	ResolvedSchedule string `json:"resolved_schedule,omitempty"`
+	Timezone string `json:"timezone"`
+	Concurrency ConcurrencyPolicyResource `json:"concurrency_policy"`
+	Rolling *domain.RollingPolicyJson `json:"rolling,omitempty"`
 	Command []string `json:"command"`
+	Paused bool `json:"paused"`
 	LastExecution *DateResource `json:"last_execution"`
 	NextExecution *DateResource `json:"next_execution"`
+	Trigger string `json:"trigger,omitempty"`
+	Upstream []JobReferenceResource `json:"upstream"`
+	Downstream []JobReferenceResource `json:"downstream"`
+}
+
+func jobReferences(names []string, host string) []JobReferenceResource {
+	references := make([]JobReferenceResource, len(names))
+	for i, name := range names {
+		references[i].Name = name
+		references[i].Href = fmt.Sprintf("http://%s/jobs/%s", host, name)
+	}
+	return references
 }
 
 func (h *JobHandler) resourceFromJob(job *domain.Job, res *JobResource, host string) {
@@ -54,11 +87,20 @@ func (h *JobHandler) resourceFromJob(job *domain.Job, res *JobResource, host str
 	res.Description = job.Description
 
 	res.Schedule = job.ScheduleSpec
+	res.Timezone = job.Location.String()
+	if job.Fuzzy {
+		res.ResolvedSchedule = job.ResolvedScheduleSpec
+	}
+	res.Concurrency.Mode = job.Concurrency.Mode
+	res.Concurrency.Limit = job.Concurrency.Limit
+	res.Rolling = job.Definition.Rolling
 
 	res.Links[0].Href = fmt.Sprintf("http://%s/jobs/%s/reports", host, job.Name)
 	res.Links[0].Rel = "reports"
 
 	res.Command = job.Command.Command()
+	// The lock is already held; IsPaused would take it again
+	res.Paused = job.Paused
 
 	res.Owners = make([]JobOwnerResource, len(job.Owners))
 	for i, owner := range job.Owners {
@@ -69,32 +111,46 @@ func (h *JobHandler) resourceFromJob(job *domain.Job, res *JobResource, host str
 	if !job.LastExecution.IsZero() {
 		res.LastExecution = &DateResource{
 			Timestamp: job.LastExecution.UnixNano(),
-			String: job.LastExecution.String(),
+			String: job.LastExecution.In(job.Location).String(),
 		}
 	} else {
 		res.LastExecution = nil
 	}
 
-	next := job.Schedule.Next(time.Now())
-	res.NextExecution = &DateResource{
-		Timestamp: next.UnixNano(),
-		String: next.String(),
+	if job.Schedule != nil {
+		next := job.Schedule.Next(time.Now()).In(job.Location)
+		res.NextExecution = &DateResource{
+			Timestamp: next.UnixNano(),
+			String: next.String(),
+		}
+	} else {
+		res.NextExecution = nil
+	}
+
+	res.Upstream = jobReferences(job.Dependency.Upstream, host)
+	res.Downstream = jobReferences(job.Downstream, host)
+	if len(job.Dependency.Upstream) > 0 {
+		res.Trigger = job.Dependency.Trigger
+	}
+
+	selection := ""
+	if job.Policy.Hosts != domain.POLICY_ALL {
+		selection = job.Policy.Selection
 	}
 
 	if len(job.Policy.Roles) > 0 {
-		res.Policy = RoleExecutionPolicyResource{job.Policy.Hosts, job.Policy.Roles}
+		res.Policy = RoleExecutionPolicyResource{job.Policy.Hosts, job.Policy.Roles, selection, job.Policy.Count, job.Policy.Percent}
 	} else {
-		res.Policy = HostsExecutionPolicyResource{job.Policy.Hosts, job.Policy.HostList}
+		res.Policy = HostsExecutionPolicyResource{job.Policy.Hosts, job.Policy.HostList, selection, job.Policy.Count, job.Policy.Percent}
 	}
 }
 
 func (h *JobHandler) JobList(resp http.ResponseWriter, req *http.Request, _ httprouter.Params) {
 	var jobs *container.JobContainer = h.server.jobs
 
-	jobCount := jobs.Count()
-	jobResources := make([]JobResource, jobCount)
-	for i := 0; i < jobCount; i ++ {
-		job := jobs.Get(i)
+	all := jobs.All()
+	jobResources := make([]JobResource, len(all))
+	for i, job := range all {
 		h.resourceFromJob(job, &jobResources[i], req.Host)
 	}
 
@@ -119,3 +175,38 @@ func (h *JobHandler) JobSingle(resp http.ResponseWriter, req *http.Request, para
 		resp.Write(jsonBody)
 	}
 }
+
+func (h *JobHandler) JobPause(resp http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	h.setPaused(resp, req, params, true)
+}
+
+func (h *JobHandler) JobResume(resp http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	h.setPaused(resp, req, params, false)
+}
+
+func (h *JobHandler) setPaused(resp http.ResponseWriter, req *http.Request, params httprouter.Params, paused bool) {
+	job, err := h.server.jobs.JobByName(params.ByName("job"))
+	if err != nil {
+		resp.WriteHeader(404)
+		return
+	}
+
+	job.SetPaused(paused)
+
+	// The instance that dispatches the job reads the flag from the storage
+	if sErr := h.server.store.SaveJobPaused(job.Name, paused); sErr != nil {
+		h.server.logger.Error(fmt.Sprintf("State of job %s could not be saved: %s", job.Name, sErr))
+		resp.WriteHeader(500)
+		return
+	}
+
+	h.server.logger.Notice("Job %s paused: %t", job.Name, paused)
+
+	res := JobResource{}
+	h.resourceFromJob(job, &res, req.Host)
+
+	jsonBody, _ := json.MarshalIndent(res, "", "  ")
+
+	resp.Header().Set("Content-Type", "application/json")
+	resp.Write(jsonBody)
+}
@@ -0,0 +1,38 @@
+package server
+
+import (
+	"testing"
+	"time"
+	"github.com/martin-helmich/distcrond/domain"
+)
+
+func TestResourceFromJobDoesNotDeadlockWithWriters(t *testing.T) {
+	handler := &JobHandler{server: &RestServer{}}
+	job := &domain.Job{Name: "backup", Command: domain.ExecCommand{}, Location: time.UTC}
+
+	done := make(chan bool)
+	for g := 0; g < 4; g ++ {
+		go func() {
+			for i := 0; i < 20000; i ++ {
+				job.SetPaused(i % 2 == 0)
+			}
+			done <- true
+		}()
+
+		go func() {
+			for i := 0; i < 20000; i ++ {
+				res := JobResource{}
+				handler.resourceFromJob(job, &res, "localhost")
+			}
+			done <- true
+		}()
+	}
+
+	for i := 0; i < 8; i ++ {
+		select {
+		case <-done:
+		case <-time.After(10 * time.Second):
+			t.Fatal("resourceFromJob deadlocked with SetPaused")
+		}
+	}
+}
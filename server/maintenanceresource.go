@@ -0,0 +1,155 @@
+package server
+
+import (
+	"github.com/martin-helmich/distcrond/domain"
+	"net/http"
+	"github.com/julienschmidt/httprouter"
+	"github.com/twinj/uuid"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"fmt"
+	"os"
+	"time"
+)
+
+type MaintenanceHandler SubHandler
+
+type MaintenanceResource struct {
+	Id string `json:"id"`
+	Href string `json:"href"`
+	Active bool `json:"active"`
+	domain.MaintenanceWindowJson
+}
+
+func (h *MaintenanceHandler) resourceFromWindow(window *domain.MaintenanceWindow, res *MaintenanceResource, host string) {
+	res.Id = window.Id
+	res.Href = fmt.Sprintf("http://%s/maintenance/%s", host, window.Id)
+	res.Active = window.IsActive(time.Now())
+	res.MaintenanceWindowJson = window.Definition
+}
+
+func (h *MaintenanceHandler) MaintenanceList(resp http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	h.removeExpired()
+
+	all := h.server.maintenance.All()
+	resources := make([]MaintenanceResource, len(all))
+	for i, window := range all {
+		h.resourceFromWindow(window, &resources[i], req.Host)
+	}
+
+	jsonBody, _ := json.MarshalIndent(resources, "", "  ")
+
+	resp.Header().Set("Content-Type", "application/json")
+	resp.Write(jsonBody)
+}
+
+func (h *MaintenanceHandler) MaintenanceSingle(resp http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	if window, err := h.server.maintenance.WindowById(params.ByName("window")); err != nil {
+		resp.WriteHeader(404)
+	} else {
+		res := MaintenanceResource{}
+		h.resourceFromWindow(window, &res, req.Host)
+
+		jsonBody, _ := json.MarshalIndent(res, "", "  ")
+
+		resp.Header().Set("Content-Type", "application/json")
+		resp.Write(jsonBody)
+	}
+}
+
+func (h *MaintenanceHandler) MaintenanceCreate(resp http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		resp.WriteHeader(400)
+		return
+	}
+
+	windowJson := domain.MaintenanceWindowJson{}
+	if err := json.Unmarshal(body, &windowJson); err != nil {
+		h.badRequest(resp, err)
+		return
+	}
+
+	id := uuid.Formatter(uuid.NewV4(), uuid.CleanHyphen)
+	window, err := domain.NewMaintenanceWindowFromJson(id, windowJson)
+	if err != nil {
+		h.badRequest(resp, err)
+		return
+	}
+
+	if err := window.IsValid(); err != nil {
+		h.badRequest(resp, err)
+		return
+	}
+
+	if err := h.persist(window); err != nil {
+		h.server.logger.Error(fmt.Sprintf("Maintenance window %s could not be saved: %s", id, err))
+		resp.WriteHeader(500)
+		return
+	}
+
+	h.removeExpired()
+	h.server.maintenance.AddWindow(window)
+	h.server.logger.Notice("Created maintenance window %s", id)
+
+	res := MaintenanceResource{}
+	h.resourceFromWindow(window, &res, req.Host)
+
+	jsonBody, _ := json.MarshalIndent(res, "", "  ")
+
+	resp.Header().Set("Content-Type", "application/json")
+	resp.Header().Set("Location", res.Href)
+	resp.WriteHeader(201)
+	resp.Write(jsonBody)
+}
+
+func (h *MaintenanceHandler) MaintenanceDelete(resp http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	id := params.ByName("window")
+	if _, err := h.server.maintenance.WindowById(id); err != nil {
+		resp.WriteHeader(404)
+		return
+	}
+
+	if len(h.server.maintenanceDirectory) > 0 {
+		if err := os.Remove(h.windowFile(id)); err != nil && !os.IsNotExist(err) {
+			h.server.logger.Error(fmt.Sprintf("Maintenance window %s could not be deleted: %s", id, err))
+			resp.WriteHeader(500)
+			return
+		}
+	}
+
+	h.server.maintenance.RemoveWindow(id)
+	h.server.logger.Notice("Deleted maintenance window %s", id)
+
+	resp.WriteHeader(204)
+}
+
+// Stores the window in the maintenance directory (if there is one), so that
+// it survives a restart.
+func (h *MaintenanceHandler) persist(window *domain.MaintenanceWindow) error {
+	if len(h.server.maintenanceDirectory) == 0 {
+		return nil
+	}
+
+	body, _ := json.MarshalIndent(window.Definition, "", "    ")
+	return ioutil.WriteFile(h.windowFile(window.Id), body, 0644)
+}
+
+// Forgets ad-hoc windows that are over. Their files are kept, but skipped when
+// the configuration is reloaded.
+func (h *MaintenanceHandler) removeExpired() {
+	for _, id := range h.server.maintenance.RemoveExpired(time.Now()) {
+		h.server.logger.Info("Maintenance window %s is over", id)
+	}
+}
+
+func (h *MaintenanceHandler) windowFile(id string) string {
+	return filepath.Join(h.server.maintenanceDirectory, id + ".json")
+}
+
+func (h *MaintenanceHandler) badRequest(resp http.ResponseWriter, err error) {
+	resp.Header().Set("Content-Type", "text/plain")
+	resp.WriteHeader(400)
+	resp.Write([]byte(err.Error()))
+}
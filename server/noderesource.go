@@ -17,6 +17,17 @@ type NodeResource struct {
 	Roles []string `json:"roles"`
 	Status string `json:"status"`
 	RunningJobs int32 `json:"running_jobs"`
+	MaxConcurrentJobs int `json:"max_concurrent_jobs,omitempty"`
+	Weight int `json:"weight"`
+	Error *NodeErrorResource `json:"error,omitempty"`
+}
+
+// Describes why a node is down.
+type NodeErrorResource struct {
+	Type string `json:"type"`
+	Message string `json:"message"`
+	HostKey string `json:"host_key,omitempty"`
+	ExpectedHostKeys []string `json:"expected_host_keys,omitempty"`
 }
 
 func (h *NodeHandler) resourceFromNode(node *domain.Node, res *NodeResource, host string) {
@@ -24,6 +35,11 @@ func (h *NodeHandler) resourceFromNode(node *domain.Node, res *NodeResource, hos
 	res.Href = fmt.Sprintf("http://%s/nodes/%s", host, node.Name)
 	res.Roles = node.Roles
 	res.RunningJobs = atomic.LoadInt32(&node.RunningJobs)
+	res.MaxConcurrentJobs = node.MaxConcurrentJobs
+	res.Weight = node.Weight
+
+	node.Lock.RLock()
+	defer node.Lock.RUnlock()
 
 	switch node.Status {
 	case domain.STATUS_DOWN:
@@ -31,13 +47,20 @@ func (h *NodeHandler) resourceFromNode(node *domain.Node, res *NodeResource, hos
 	default:
 		res.Status = "up"
 	}
+
+	switch err := node.DownError.(type) {
+	case nil:
+	case *domain.HostKeyMismatchError:
+		res.Error = &NodeErrorResource{Type: "host_key_mismatch", Message: err.Error(), HostKey: err.Fingerprint, ExpectedHostKeys: err.Expected}
+	default:
+		res.Error = &NodeErrorResource{Type: "unreachable", Message: err.Error()}
+	}
 }
 
 func (h *NodeHandler) NodeList(resp http.ResponseWriter, req *http.Request, _ httprouter.Params) {
-	nodeCount := h.server.nodes.Count()
-	nodeResources := make([]NodeResource, nodeCount)
-	for i := 0; i < nodeCount; i ++ {
-		node := h.server.nodes.Get(i)
+	all := h.server.nodes.All()
+	nodeResources := make([]NodeResource, len(all))
+	for i, node := range all {
 		h.resourceFromNode(node, &nodeResources[i], req.Host)
 	}
 
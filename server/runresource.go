@@ -0,0 +1,195 @@
+package server
+
+import (
+	"github.com/martin-helmich/distcrond/domain"
+	"net/http"
+	"github.com/julienschmidt/httprouter"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+type RunHandler SubHandler
+
+type RunResource struct {
+	Id string `json:"id"`
+	Href string `json:"href"`
+	Job JobReferenceResource `json:"job"`
+	Status string `json:"status"`
+	OutputHref string `json:"output_href"`
+	Report *domain.RunReportJson `json:"report"`
+}
+
+func (h *RunHandler) resourceFromRun(run *domain.RunReport, res *RunResource, host string) {
+	res.Id = run.Id
+	res.Href = fmt.Sprintf("http://%s/runs/%s", host, run.Id)
+	res.Job.Name = run.Job.Name
+	res.Job.Href = fmt.Sprintf("http://%s/jobs/%s", host, run.Job.Name)
+	res.Status = run.Phase()
+	res.OutputHref = fmt.Sprintf("http://%s/runs/%s/output", host, run.Id)
+
+	// The report is still being written while the run is active
+	if res.Status == domain.RUN_FINISHED {
+		report := run.ToJson()
+		res.Report = &report
+	} else {
+		res.Report = nil
+	}
+}
+
+func (h *RunHandler) RunCreate(resp http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	job, err := h.server.jobs.JobByName(params.ByName("job"))
+	if err != nil {
+		resp.WriteHeader(404)
+		return
+	}
+
+	run, tErr := h.server.dispatcher.Trigger(job)
+	if tErr != nil {
+		h.server.logger.Error(fmt.Sprintf("Job %s could not be triggered: %s", job.Name, tErr))
+		resp.WriteHeader(503)
+		return
+	}
+
+	h.server.logger.Notice("Manually triggered run %s of job %s", run.Id, job.Name)
+
+	res := RunResource{}
+	h.resourceFromRun(run, &res, req.Host)
+
+	jsonBody, _ := json.MarshalIndent(res, "", "  ")
+
+	resp.Header().Set("Content-Type", "application/json")
+	resp.Header().Set("Location", res.Href)
+	resp.WriteHeader(202)
+	resp.Write(jsonBody)
+}
+
+func (h *RunHandler) RunSingle(resp http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	if run, err := h.server.runs.RunById(params.ByName("run")); err != nil {
+		resp.WriteHeader(404)
+	} else {
+		res := RunResource{}
+		h.resourceFromRun(run, &res, req.Host)
+
+		jsonBody, _ := json.MarshalIndent(res, "", "  ")
+
+		resp.Header().Set("Content-Type", "application/json")
+		resp.Write(jsonBody)
+	}
+}
+
+type OutputChunkResource struct {
+	Time string `json:"time"`
+	Node string `json:"node"`
+	Stream string `json:"stream"`
+	Text string `json:"text"`
+}
+
+// Streams the output of a run while it is produced, starting with the output
+// that was written before. Clients that accept "text/event-stream" receive
+// Server-Sent Events; all others receive the raw output. Once the run is
+// finished, its output is taken from the report.
+func (h *RunHandler) RunOutput(resp http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	run, err := h.server.runs.RunById(params.ByName("run"))
+	if err != nil {
+		resp.WriteHeader(404)
+		return
+	}
+
+	flusher, ok := resp.(http.Flusher)
+	if !ok {
+		resp.WriteHeader(501)
+		return
+	}
+
+	live := run.Live != nil && run.Live.Follow()
+	if live {
+		defer run.Live.Unfollow()
+	}
+
+	events := strings.Contains(req.Header.Get("Accept"), "text/event-stream")
+	if events {
+		resp.Header().Set("Content-Type", "text/event-stream")
+	} else {
+		resp.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	}
+	resp.Header().Set("Cache-Control", "no-cache")
+	resp.WriteHeader(200)
+	flusher.Flush()
+
+	var disconnected <-chan bool
+	if notifier, ok := resp.(http.CloseNotifier); ok {
+		disconnected = notifier.CloseNotify()
+	}
+
+	if !live {
+		for _, chunk := range reportChunks(run) {
+			writeChunk(resp, chunk, events)
+		}
+		writeEnd(resp, run, events)
+		flusher.Flush()
+		return
+	}
+
+	position := 0
+	for {
+		chunks, next, closed, changed := run.Live.Read(position)
+		if next - len(chunks) > position {
+			if events {
+				fmt.Fprintf(resp, "event: truncated\ndata: {}\n\n")
+			} else {
+				resp.Write([]byte("\n[... output truncated ...]\n"))
+			}
+		}
+		position = next
+
+		for _, chunk := range chunks {
+			writeChunk(resp, chunk, events)
+		}
+
+		if closed {
+			writeEnd(resp, run, events)
+			flusher.Flush()
+			return
+		}
+
+		flusher.Flush()
+
+		select {
+		case <-changed:
+		case <-disconnected:
+			return
+		}
+	}
+}
+
+// Builds the output of a finished run from its report, one chunk per node and
+// stream.
+func reportChunks(run *domain.RunReport) []domain.OutputChunk {
+	chunks := make([]domain.OutputChunk, 0, len(run.Items) * 2)
+	for _, item := range run.Items {
+		if len(item.Output) > 0 {
+			chunks = append(chunks, domain.OutputChunk{Time: item.Time.Stop, Node: item.NodeName(), Stream: domain.STREAM_STDOUT, Data: []byte(item.Output)})
+		}
+		if len(item.Stderr) > 0 {
+			chunks = append(chunks, domain.OutputChunk{Time: item.Time.Stop, Node: item.NodeName(), Stream: domain.STREAM_STDERR, Data: []byte(item.Stderr)})
+		}
+	}
+	return chunks
+}
+
+func writeChunk(resp http.ResponseWriter, chunk domain.OutputChunk, events bool) {
+	if events {
+		t, _ := chunk.Time.MarshalText()
+		data, _ := json.Marshal(OutputChunkResource{string(t), chunk.Node, chunk.Stream, string(chunk.Data)})
+		fmt.Fprintf(resp, "event: output\ndata: %s\n\n", data)
+	} else {
+		resp.Write(chunk.Data)
+	}
+}
+
+func writeEnd(resp http.ResponseWriter, run *domain.RunReport, events bool) {
+	if events {
+		fmt.Fprintf(resp, "event: end\ndata: {\"status\": \"%s\"}\n\n", run.Status())
+	}
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"github.com/julienschmidt/httprouter"
+	"github.com/martin-helmich/distcrond/container"
+	"github.com/martin-helmich/distcrond/domain"
+)
+
+func TestRunOutputSendsServerSentEvents(t *testing.T) {
+	runs := container.NewRunContainer(10)
+	handler := &RunHandler{server: &RestServer{runs: runs}}
+
+	run := domain.NewRunReport(&domain.Job{Name: "backup"})
+	runs.AddRun(run)
+
+	// Keep the output from being released when the run finishes
+	run.Live.Follow()
+
+	run.Live.Write("node1", domain.STREAM_STDOUT, []byte("hello\n"))
+	run.SetPhase(domain.RUN_FINISHED)
+
+	req, _ := http.NewRequest("GET", "/runs/" + run.Id + "/output", nil)
+	req.Header.Set("Accept", "text/event-stream")
+	resp := httptest.NewRecorder()
+
+	handler.RunOutput(resp, req, httprouter.Params{{Key: "run", Value: run.Id}})
+	run.Live.Unfollow()
+
+	if resp.Code != 200 {
+		t.Fatalf("Expected status 200, got %d", resp.Code)
+	}
+
+	if contentType := resp.Header().Get("Content-Type"); contentType != "text/event-stream" {
+		t.Errorf("Unexpected content type %s", contentType)
+	}
+
+	events := strings.Split(strings.TrimSuffix(resp.Body.String(), "\n\n"), "\n\n")
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 events, got %q", resp.Body.String())
+	}
+
+	if !strings.HasPrefix(events[0], "event: output\ndata: {") || !strings.Contains(events[0], `"node":"node1","stream":"stdout","text":"hello\n"}`) {
+		t.Errorf("Unexpected output event %q", events[0])
+	}
+
+	if events[1] != "event: end\ndata: {\"status\": \"" + run.Status() + "\"}" {
+		t.Errorf("Unexpected end event %q", events[1])
+	}
+}
+
+func TestRunOutputTakesOutputOfFinishedRunsFromReport(t *testing.T) {
+	runs := container.NewRunContainer(10)
+	handler := &RunHandler{server: &RestServer{runs: runs}}
+
+	run := domain.NewRunReport(&domain.Job{Name: "backup"})
+	run.Initialize(run.Job, 1)
+	runs.AddRun(run)
+
+	run.Live.Write("node1", domain.STREAM_STDOUT, []byte("hello\n"))
+	run.Items[0].Output = "hello\n"
+	run.Items[0].Stderr = "warning\n"
+	run.SetPhase(domain.RUN_FINISHED)
+
+	if chunks, _, _, _ := run.Live.Read(0); len(chunks) != 0 {
+		t.Errorf("Expected live output to be released, got %d chunks", len(chunks))
+	}
+
+	req, _ := http.NewRequest("GET", "/runs/" + run.Id + "/output", nil)
+	resp := httptest.NewRecorder()
+
+	handler.RunOutput(resp, req, httprouter.Params{{Key: "run", Value: run.Id}})
+
+	if resp.Body.String() != "hello\nwarning\n" {
+		t.Errorf("Unexpected output %q", resp.Body.String())
+	}
+}
+
+func TestRunOutputReturns404ForUnknownRuns(t *testing.T) {
+	handler := &RunHandler{server: &RestServer{runs: container.NewRunContainer(10)}}
+
+	req, _ := http.NewRequest("GET", "/runs/unknown/output", nil)
+	resp := httptest.NewRecorder()
+
+	handler.RunOutput(resp, req, httprouter.Params{{Key: "run", Value: "unknown"}})
+
+	if resp.Code != 404 {
+		t.Errorf("Expected status 404, got %d", resp.Code)
+	}
+}
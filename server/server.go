@@ -9,6 +9,8 @@ import (
 	"github.com/martin-helmich/distcrond/storage"
 	"time"
 	"encoding/json"
+	"github.com/martin-helmich/distcrond/domain"
+	"github.com/martin-helmich/distcrond/election"
 )
 
 type LinkResource struct {
@@ -16,8 +18,23 @@ type LinkResource struct {
 	Rel string `json:"rel"`
 }
 
+type LeaderResource struct {
+	Instance string `json:"instance"`
+	Address string `json:"address"`
+	Self bool `json:"self"`
+	Since *DateResource `json:"since"`
+	Expires *DateResource `json:"expires"`
+}
+
 type RootResource struct {
 	Links []LinkResource `json:"links"`
+	Instance string `json:"instance,omitempty"`
+	Leader *LeaderResource `json:"leader,omitempty"`
+}
+
+// Starts job runs on request
+type RunDispatcher interface {
+	Trigger(job *domain.Job) (*domain.RunReport, error)
 }
 
 type RestServer struct {
@@ -26,6 +43,11 @@ type RestServer struct {
 
 	nodes *container.NodeContainer
 	jobs *container.JobContainer
+	runs *container.RunContainer
+	maintenance *container.MaintenanceContainer
+	maintenanceDirectory string
+	dispatcher RunDispatcher
+	elector *election.Elector
 	store storage.StorageBackend
 	logger *logging.Logger
 
@@ -49,9 +71,25 @@ func (s *RestServer) decorate(handler httprouter.Handle) httprouter.Handle {
 }
 
 func (h *RestServer) RootHandler(resp http.ResponseWriter, req *http.Request, param httprouter.Params) {
-	root := h.root
-	root.Links[0].Href = fmt.Sprintf("http://%s/jobs", req.Host)
-	root.Links[1].Href = fmt.Sprintf("http://%s/nodes", req.Host)
+	root := *h.root
+	root.Links = []LinkResource {
+		LinkResource{fmt.Sprintf("http://%s/jobs", req.Host), "jobs"},
+		LinkResource{fmt.Sprintf("http://%s/nodes", req.Host), "nodes"},
+		LinkResource{fmt.Sprintf("http://%s/maintenance", req.Host), "maintenance"},
+	}
+
+	if h.elector != nil {
+		root.Instance = h.elector.Instance()
+		if lease := h.elector.Leader(); len(lease.Instance) > 0 {
+			root.Leader = &LeaderResource{
+				Instance: lease.Instance,
+				Address: lease.Address,
+				Self: lease.Instance == root.Instance,
+				Since: &DateResource{lease.Acquired.UnixNano(), lease.Acquired.String()},
+				Expires: &DateResource{lease.Expires.UnixNano(), lease.Expires.String()},
+			}
+		}
+	}
 
 	resp.Header().Set("Content-Type", "application/json")
 
@@ -64,13 +102,19 @@ func (h *RestServer) buildRootResource() {
 	h.root.Links = []LinkResource {
 		LinkResource{"/jobs", "jobs"},
 		LinkResource{"/nodes", "nodes"},
+		LinkResource{"/maintenance", "maintenance"},
 	}
 }
 
-func NewRestServer(port int, nodes *container.NodeContainer, jobs *container.JobContainer, store storage.StorageBackend, logger *logging.Logger) *RestServer {
+func NewRestServer(port int, nodes *container.NodeContainer, jobs *container.JobContainer, runs *container.RunContainer, maintenance *container.MaintenanceContainer, maintenanceDirectory string, dispatcher RunDispatcher, elector *election.Elector, store storage.StorageBackend, logger *logging.Logger) *RestServer {
 	server := new(RestServer)
 	server.nodes = nodes
 	server.jobs = jobs
+	server.runs = runs
+	server.maintenance = maintenance
+	server.maintenanceDirectory = maintenanceDirectory
+	server.dispatcher = dispatcher
+	server.elector = elector
 	server.logger = logger
 	server.store = store
 	server.buildRootResource()
@@ -78,6 +122,8 @@ func NewRestServer(port int, nodes *container.NodeContainer, jobs *container.Job
 	nodehandler := NodeHandler{server}
 	jobhandler := JobHandler{server}
 	reporthandler := ReportHandler{server}
+	runhandler := RunHandler{server}
+	maintenancehandler := MaintenanceHandler{server}
 
 	router := httprouter.New()
 	router.GET("/", server.decorate(server.RootHandler))
@@ -86,6 +132,15 @@ func NewRestServer(port int, nodes *container.NodeContainer, jobs *container.Job
 	router.GET("/jobs", server.decorate(jobhandler.JobList))
 	router.GET("/jobs/:job", server.decorate(jobhandler.JobSingle))
 	router.GET("/jobs/:job/reports", server.decorate(reporthandler.ReportsByJob))
+	router.POST("/jobs/:job/pause", server.decorate(jobhandler.JobPause))
+	router.POST("/jobs/:job/resume", server.decorate(jobhandler.JobResume))
+	router.POST("/jobs/:job/runs", server.decorate(runhandler.RunCreate))
+	router.GET("/runs/:run", server.decorate(runhandler.RunSingle))
+	router.GET("/runs/:run/output", server.decorate(runhandler.RunOutput))
+	router.GET("/maintenance", server.decorate(maintenancehandler.MaintenanceList))
+	router.POST("/maintenance", server.decorate(maintenancehandler.MaintenanceCreate))
+	router.GET("/maintenance/:window", server.decorate(maintenancehandler.MaintenanceSingle))
+	router.DELETE("/maintenance/:window", server.decorate(maintenancehandler.MaintenanceDelete))
 
 	server.mux = router
 	server.server = http.Server{
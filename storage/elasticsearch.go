@@ -8,6 +8,7 @@ import (
 	"errors"
 	"strings"
 	"encoding/json"
+	"io/ioutil"
 )
 
 type ElasticsearchBackend struct {
@@ -85,6 +86,65 @@ func (e *ElasticsearchBackend) request(path string, method string, body string)
 	return nil
 }
 
+// Uses a partial update, so that the paused flag is left alone.
+func (e *ElasticsearchBackend) SaveJobState(state domain.JobStateJson) error {
+	encoded, _ := json.Marshal(state)
+
+	doc := make(map[string]interface{})
+	json.Unmarshal(encoded, &doc)
+	delete(doc, "paused")
+
+	return e.updateJobState(state.Job, doc)
+}
+
+func (e *ElasticsearchBackend) SaveJobPaused(job string, paused bool) error {
+	return e.updateJobState(job, map[string]interface{}{"job": job, "paused": paused})
+}
+
+func (e *ElasticsearchBackend) updateJobState(job string, doc map[string]interface{}) error {
+	body, _ := json.Marshal(map[string]interface{}{"doc": doc, "doc_as_upsert": true})
+
+	if err := e.requestDocument("jobstate", job + "/_update", "POST", string(body)); err != nil {
+		e.logger.Error(fmt.Sprintf("Error while persisting state of job %s: %s", job, err))
+		return err
+	}
+
+	return nil
+}
+
+func (e *ElasticsearchBackend) LoadJobState(job *domain.Job) (domain.JobStateJson, error) {
+	state := domain.JobStateJson{Job: job.Name}
+	uri := fmt.Sprintf("%s/%s/jobstate/%s", e.uri, e.index, job.Name)
+
+	resp, err := e.client.Get(uri)
+	if err != nil {
+		return state, errors.New(fmt.Sprintf("Error while requesting %s: %s", uri, err))
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return state, nil
+	} else if resp.StatusCode >= 300 {
+		return state, errors.New(fmt.Sprintf("Unexpected status code %d while requesting %s", resp.StatusCode, uri))
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return state, err
+	}
+
+	document := struct {
+		Source domain.JobStateJson `json:"_source"`
+	}{state}
+
+	if err := json.Unmarshal(body, &document); err != nil {
+		return state, err
+	}
+
+	return document.Source, nil
+}
+
 func (p *ElasticsearchBackend) ReportsForJob(job *domain.Job) ([]domain.RunReportJson, error) {
 	// TODO: Implement me!
 	reports := make([]domain.RunReportJson, 0)
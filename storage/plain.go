@@ -8,6 +8,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sync"
 	"sync/atomic"
 	"time"
 )
@@ -16,6 +17,10 @@ type PlainFileStorageBackend struct {
 	logDirectory string
 	logger *logging.Logger
 	counter int64
+
+	// Serializes writes to the state file of each job
+	stateLocks map[string]*sync.Mutex
+	stateLocksLock sync.Mutex
 //	reports []domain.RunReportJson
 //	timer *time.Timer
 }
@@ -26,6 +31,7 @@ func NewPlainStorageBackend(logDirectory string) *PlainFileStorageBackend {
 		logDirectory: logDirectory,
 		logger: logger,
 		counter: 0,
+		stateLocks: make(map[string]*sync.Mutex),
 //		make([]domain.RunReportJson, 0, 64),
 	}
 }
@@ -89,3 +95,93 @@ func (p *PlainFileStorageBackend) ReportsForJob(job *domain.Job) ([]domain.RunRe
 
 	return reports, nil
 }
+
+func (p *PlainFileStorageBackend) stateFilename(job string) string {
+	// Dot-prefixed, so that these files are not mistaken for reports
+	return fmt.Sprintf("%s/.state-%s.json", p.logDirectory, job)
+}
+
+func (p *PlainFileStorageBackend) stateLock(job string) *sync.Mutex {
+	p.stateLocksLock.Lock()
+	defer p.stateLocksLock.Unlock()
+
+	lock, ok := p.stateLocks[job]
+	if !ok {
+		lock = new(sync.Mutex)
+		p.stateLocks[job] = lock
+	}
+	return lock
+}
+
+// Timestamps are never moved backwards, even when states saved at the same
+// time are written out of order.
+func (p *PlainFileStorageBackend) SaveJobState(state domain.JobStateJson) error {
+	lock := p.stateLock(state.Job)
+	lock.Lock()
+	defer lock.Unlock()
+
+	if previous, err := p.readJobState(state.Job); err == nil {
+		state = state.MergeTimes(previous)
+		state.Paused = previous.Paused
+	}
+
+	return p.writeJobState(state)
+}
+
+func (p *PlainFileStorageBackend) SaveJobPaused(job string, paused bool) error {
+	lock := p.stateLock(job)
+	lock.Lock()
+	defer lock.Unlock()
+
+	state, err := p.readJobState(job)
+	if err != nil {
+		p.logger.Warning("Could not read state of job %s, starting over: %s", job, err)
+		state = domain.JobStateJson{Job: job}
+	}
+
+	state.Paused = paused
+	return p.writeJobState(state)
+}
+
+// Writes the state to a temporary file first, so that a crash never leaves a
+// partially written state file behind. Must be called with the job's state
+// lock held.
+func (p *PlainFileStorageBackend) writeJobState(state domain.JobStateJson) error {
+	body, _ := json.MarshalIndent(state, "", "    ")
+	filename := p.stateFilename(state.Job)
+	temp := fmt.Sprintf("%s.%d.tmp", filename, os.Getpid())
+
+	err := ioutil.WriteFile(temp, body, 0644)
+	if err == nil {
+		err = os.Rename(temp, filename)
+	}
+
+	if err != nil {
+		os.Remove(temp)
+		p.logger.Error(fmt.Sprintf("Error while persisting state of job %s: %s", state.Job, err))
+		return err
+	}
+
+	return nil
+}
+
+func (p *PlainFileStorageBackend) LoadJobState(job *domain.Job) (domain.JobStateJson, error) {
+	return p.readJobState(job.Name)
+}
+
+func (p *PlainFileStorageBackend) readJobState(job string) (domain.JobStateJson, error) {
+	state := domain.JobStateJson{Job: job}
+
+	content, err := ioutil.ReadFile(p.stateFilename(job))
+	if os.IsNotExist(err) {
+		return state, nil
+	} else if err != nil {
+		return state, err
+	}
+
+	if err := json.Unmarshal(content, &state); err != nil {
+		return state, err
+	}
+
+	return state, nil
+}
@@ -20,6 +20,15 @@ type StorageBackend interface {
 	Disconnect() error
 	SaveReport(report *domain.RunReport) error
 	ReportsForJob(job *domain.Job) ([]domain.RunReportJson, error)
+
+	// Job state is persisted so that it survives restarts. Loading the
+	// state of a job that has never been saved yields an empty state.
+	// SaveJobState leaves the paused flag alone; it is only changed by
+	// SaveJobPaused, so that a pause requested on another instance is
+	// never overwritten with outdated in-memory state.
+	SaveJobState(state domain.JobStateJson) error
+	SaveJobPaused(job string, paused bool) error
+	LoadJobState(job *domain.Job) (domain.JobStateJson, error)
 }
 
 func BuildStorageBackend(config StorageBackendConfiguration) (StorageBackend, error) {